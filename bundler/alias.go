@@ -0,0 +1,66 @@
+package bundler
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A PathAlias maps import paths matching Pattern to the absolute paths in Targets.
+// Pattern may contain a single '*' wildcard, whose match is substituted for the '*' in each target.
+type PathAlias struct {
+	Pattern string
+	Targets []string
+}
+
+// NewPathAliases converts a map of import prefixes to paths relative to root into path aliases.
+// A prefix ending in '/' only matches paths below it (e.g. "@/" to "src/"),
+// any other prefix also matches itself (e.g. "~components" to "src/components").
+func NewPathAliases(aliases map[string]string, root string) []PathAlias {
+	r := make([]PathAlias, 0, 2*len(aliases))
+	for prefix, target := range aliases {
+		target = filepath.Join(root, target)
+		if !strings.HasSuffix(prefix, "/") {
+			r = append(r, PathAlias{prefix, []string{target}})
+			prefix += "/"
+		}
+		r = append(r, PathAlias{prefix + "*", []string{filepath.Join(target, "*")}})
+	}
+	return r
+}
+
+// Match returns the targets of the alias with the wildcard substituted if path matches its pattern.
+func (a PathAlias) Match(path string) ([]string, bool) {
+	prefix, suffix, wildcard := strings.Cut(a.Pattern, "*")
+	if !wildcard {
+		return a.Targets, path == a.Pattern
+	}
+	if len(path) < len(prefix)+len(suffix) || !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, suffix) {
+		return nil, false
+	}
+	match := path[len(prefix) : len(path)-len(suffix)]
+	targets := make([]string, len(a.Targets))
+	for i, target := range a.Targets {
+		targets[i] = strings.Replace(target, "*", match, 1)
+	}
+	return targets, true
+}
+
+// prefix returns the part of the pattern before the wildcard.
+func (a PathAlias) prefix() string {
+	prefix, _, _ := strings.Cut(a.Pattern, "*")
+	return prefix
+}
+
+// sortPathAliases orders aliases from the most to the least specific, putting exact patterns first and
+// wildcard patterns with longer prefixes before those with shorter ones.
+func sortPathAliases(aliases []PathAlias) {
+	sort.SliceStable(aliases, func(i, j int) bool {
+		pi, pj := aliases[i].prefix(), aliases[j].prefix()
+		wi, wj := pi != aliases[i].Pattern, pj != aliases[j].Pattern
+		if wi != wj {
+			return !wi
+		}
+		return len(pi) > len(pj)
+	})
+}
@@ -0,0 +1,94 @@
+package bundler
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestPathAliasMatch(t *testing.T) {
+	root := filepath.FromSlash("/project")
+	aliases := NewPathAliases(map[string]string{
+		"@/":          "src/",
+		"~components": "src/components",
+	}, root)
+	sortPathAliases(aliases)
+
+	tcs := []struct {
+		path string
+		want string
+	}{
+		{"@/main.js", "/project/src/main.js"},
+		{"~components", "/project/src/components"},
+		{"~components/button.js", "/project/src/components/button.js"},
+		{"~componentsx", ""},
+		{"@main.js", ""},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.path, func(t *testing.T) {
+			var got []string
+			for _, alias := range aliases {
+				if targets, ok := alias.Match(tc.path); ok {
+					got = targets
+					break
+				}
+			}
+			if tc.want == "" {
+				if got != nil {
+					t.Errorf("got %v, want no match", got)
+				}
+			} else if want := []string{filepath.FromSlash(tc.want)}; !slices.Equal(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPathAliasDocument(t *testing.T) {
+	root := writeFiles(t, t.TempDir(), map[string]string{
+		"src/main.js":              "import '~components/button.js';",
+		"src/components/button.js": "console.log('button');",
+		"src/style.css":            "p { margin: 0 }",
+		"src/logo.png":             "png",
+	})
+	options := BuildOptions{
+		Mode:                BuildModeDevelopment,
+		OutputDirectory:     "dist",
+		ProjectRootAbsolute: root,
+		Aliases: NewPathAliases(map[string]string{
+			"@/":          "src/",
+			"~components": "src/components",
+		}, root),
+		Loaders: DefaultLoaders,
+	}
+
+	src := `<script type=module src="@/main.js"></script><link rel=stylesheet href="@/style.css"><img src="@/logo.png">`
+	doc, err := NewDocument(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = doc.Walk(context.Background(), func(ctx context.Context, path string) (string, error) {
+		result, err := Build(ctx, path, options)
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(filepath.Join(root, "dist"), result.Entry)
+		return "./" + filepath.ToSlash(rel), err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if _, err := doc.WriteSourceTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `<script type=module src="./main.js"></script><link rel=stylesheet href="./style.css"><img src="./logo.png">`
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
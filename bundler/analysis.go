@@ -0,0 +1,314 @@
+package bundler
+
+import (
+	"fmt"
+	"hash/fnv"
+	"html/template"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The analysis treemaps are laid out on a canvas of this size and scaled to the page width by the browser.
+const (
+	treemapWidth  = 1200
+	treemapHeight = 600
+	treemapHeader = 18 // the height of package labels
+)
+
+// A BundleAnalysis breaks down the contents of an output file by package and module.
+type BundleAnalysis struct {
+	Input  string // the entry point as referenced in the document
+	Output string // the output path relative to the output directory
+	Bytes  int
+	Nodes  []TreemapNode
+	Rows   []ModuleAnalysis
+}
+
+// A ModuleAnalysis explains the contribution of a module to an output.
+type ModuleAnalysis struct {
+	Package string
+	Path    string
+	Bytes   int
+	Chain   []string // the import chain from the entry point to the module
+}
+
+// A TreemapNode is a rectangle of the treemap, positioned in percent of the canvas.
+type TreemapNode struct {
+	Left, Top, Width, Height float64
+	Label, Title             string
+	Package                  bool
+	Hue                      uint32
+}
+
+type treemapRect struct {
+	x, y, w, h float64
+}
+
+// NewBundleAnalysis analyzes every output of the recorded builds that contains at least one module.
+func NewBundleAnalysis(records []BuildRecord, root, outdir string) ([]BundleAnalysis, error) {
+	var analyses []BundleAnalysis
+	for _, record := range records {
+		meta, err := ParseMetafile(record.Result.Metafile)
+		if err != nil {
+			return nil, err
+		}
+
+		paths := make([]string, 0, len(meta.Outputs))
+		for path := range meta.Outputs {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		// chunks loaded by dynamic imports are entry points in the metafile, too
+		chains := importChains(meta, record.Output.Source)
+
+		for _, path := range paths {
+			output := meta.Outputs[path]
+			analysis := BundleAnalysis{Input: record.Input, Bytes: output.Bytes}
+			if rel, err := filepath.Rel(outdir, filepath.Join(root, path)); err != nil {
+				return nil, err
+			} else {
+				analysis.Output = filepath.ToSlash(rel)
+			}
+
+			for module, input := range output.Inputs {
+				if input.BytesInOutput > 0 {
+					analysis.Rows = append(analysis.Rows, ModuleAnalysis{packageName(module), module, input.BytesInOutput, chains[module]})
+				}
+			}
+			if len(analysis.Rows) == 0 {
+				continue
+			}
+			sort.Slice(analysis.Rows, func(i, j int) bool {
+				if analysis.Rows[i].Bytes != analysis.Rows[j].Bytes {
+					return analysis.Rows[i].Bytes > analysis.Rows[j].Bytes
+				}
+				return analysis.Rows[i].Path < analysis.Rows[j].Path
+			})
+			analysis.Nodes = layoutTreemap(analysis.Rows)
+			analyses = append(analyses, analysis)
+		}
+	}
+	return analyses, nil
+}
+
+// WriteBundleAnalysis renders the analyses as a self-contained HTML page.
+func WriteBundleAnalysis(w io.Writer, analyses []BundleAnalysis) error {
+	return analysisTemplate.Execute(w, analyses)
+}
+
+// importChains returns the shortest import chain from the entry point to each reachable input of the metafile.
+func importChains(meta *Metafile, entry string) map[string][]string {
+	chains := map[string][]string{entry: {entry}}
+	for queue := []string{entry}; len(queue) > 0; queue = queue[1:] {
+		path := queue[0]
+		for _, imp := range meta.Inputs[path].Imports {
+			if _, ok := chains[imp.Path]; ok || imp.External {
+				continue
+			}
+			chain := append(append([]string(nil), chains[path]...), imp.Path)
+			chains[imp.Path] = chain
+			queue = append(queue, imp.Path)
+		}
+	}
+	return chains
+}
+
+// packageName returns the name of the package in node_modules that contains path or "(project)" for other paths.
+func packageName(path string) string {
+	i := strings.LastIndex(path, "node_modules/")
+	if i < 0 {
+		return "(project)"
+	}
+	parts := strings.SplitN(path[i+len("node_modules/"):], "/", 3)
+	if strings.HasPrefix(parts[0], "@") && len(parts) > 1 {
+		return parts[0] + "/" + parts[1]
+	}
+	return parts[0]
+}
+
+// layoutTreemap lays out the modules grouped by package; rows must be sorted by size in descending order.
+func layoutTreemap(rows []ModuleAnalysis) []TreemapNode {
+	var packages []string
+	modules := make(map[string][]ModuleAnalysis)
+	sizes := make(map[string]int)
+	for _, row := range rows {
+		if _, ok := modules[row.Package]; !ok {
+			packages = append(packages, row.Package)
+		}
+		modules[row.Package] = append(modules[row.Package], row)
+		sizes[row.Package] += row.Bytes
+	}
+	sort.SliceStable(packages, func(i, j int) bool { return sizes[packages[i]] > sizes[packages[j]] })
+
+	total := 0
+	for _, size := range sizes {
+		total += size
+	}
+
+	var nodes []TreemapNode
+	canvas := treemapRect{0, 0, treemapWidth, treemapHeight}
+	rects := squarify(mapInts(packages, func(p string) int { return sizes[p] }), canvas)
+	for i, pkg := range packages {
+		r, hue := rects[i], hash(pkg)%360
+		title := fmt.Sprintf("%s\n%s (%.1f%%)", pkg, formatSize(sizes[pkg]), 100*float64(sizes[pkg])/float64(total))
+		nodes = append(nodes, newTreemapNode(r, pkg, title, true, hue))
+
+		inner := treemapRect{r.x + 2, r.y + treemapHeader, r.w - 4, r.h - treemapHeader - 2}
+		if inner.w <= 0 || inner.h <= 0 {
+			continue
+		}
+		children := modules[pkg]
+		for j, c := range squarify(mapInts(children, func(m ModuleAnalysis) int { return m.Bytes }), inner) {
+			m := children[j]
+			title := fmt.Sprintf("%s\n%s (%.1f%%)\n\n%s", m.Path, formatSize(m.Bytes), 100*float64(m.Bytes)/float64(total), strings.Join(m.Chain, "\n→ "))
+			nodes = append(nodes, newTreemapNode(c, filepath.Base(m.Path), title, false, hue))
+		}
+	}
+	return nodes
+}
+
+func newTreemapNode(r treemapRect, label, title string, pkg bool, hue uint32) TreemapNode {
+	return TreemapNode{
+		Left:    100 * r.x / treemapWidth,
+		Top:     100 * r.y / treemapHeight,
+		Width:   100 * r.w / treemapWidth,
+		Height:  100 * r.h / treemapHeight,
+		Label:   label,
+		Title:   title,
+		Package: pkg,
+		Hue:     hue,
+	}
+}
+
+// squarify divides r into rectangles whose areas are proportional to sizes, which must be sorted in descending order,
+// keeping their aspect ratios close to 1.
+func squarify(sizes []float64, r treemapRect) []treemapRect {
+	var total float64
+	for _, size := range sizes {
+		total += size
+	}
+	if total == 0 {
+		return make([]treemapRect, len(sizes))
+	}
+	scaled := make([]float64, len(sizes))
+	for i, size := range sizes {
+		scaled[i] = size * r.w * r.h / total
+	}
+
+	rects := make([]treemapRect, 0, len(sizes))
+	for len(scaled) > 0 {
+		side := r.w
+		if r.h < side {
+			side = r.h
+		}
+		n := 1
+		for n < len(scaled) && worstAspectRatio(scaled[:n+1], side) <= worstAspectRatio(scaled[:n], side) {
+			n++
+		}
+
+		var sum float64
+		for _, size := range scaled[:n] {
+			sum += size
+		}
+		if r.w >= r.h {
+			w, y := sum/r.h, r.y
+			for _, size := range scaled[:n] {
+				rects = append(rects, treemapRect{r.x, y, w, size / w})
+				y += size / w
+			}
+			r.x, r.w = r.x+w, r.w-w
+		} else {
+			h, x := sum/r.w, r.x
+			for _, size := range scaled[:n] {
+				rects = append(rects, treemapRect{x, r.y, size / h, h})
+				x += size / h
+			}
+			r.y, r.h = r.y+h, r.h-h
+		}
+		scaled = scaled[n:]
+	}
+	return rects
+}
+
+func worstAspectRatio(row []float64, side float64) float64 {
+	var sum float64
+	min, max := row[0], row[0]
+	for _, size := range row {
+		sum += size
+		if size < min {
+			min = size
+		}
+		if size > max {
+			max = size
+		}
+	}
+	if min == 0 {
+		return 1e300
+	}
+	a, b := side*side*max/(sum*sum), sum*sum/(side*side*min)
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func mapInts[T any](s []T, f func(T) int) []float64 {
+	r := make([]float64, len(s))
+	for i := range s {
+		r[i] = float64(f(s[i]))
+	}
+	return r
+}
+
+func hash(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
+
+var analysisTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"size": formatSize,
+	"join": strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Bundle analysis</title>
+<style>
+body { font: 13px/1.4 system-ui, sans-serif; margin: 2em; color: #222; }
+.treemap { position: relative; width: 100%; aspect-ratio: 2 / 1; background: #eee; }
+.node { position: absolute; box-sizing: border-box; overflow: hidden; white-space: nowrap; text-overflow: ellipsis; padding: 1px 3px; border: 1px solid #fff; }
+.node.package { font-weight: bold; }
+.node:hover { outline: 2px solid #000; z-index: 1; }
+table { border-collapse: collapse; margin-top: 1em; }
+th, td { text-align: left; padding: 2px 8px; vertical-align: top; }
+td.size { text-align: right; white-space: nowrap; }
+td.chain { color: #666; }
+</style>
+</head>
+<body>
+<h1>Bundle analysis</h1>
+{{range .}}
+<section>
+<h2>{{.Output}} <small>({{size .Bytes}}, built from {{.Input}})</small></h2>
+<div class="treemap">
+{{- range .Nodes}}
+<div class="node{{if .Package}} package{{end}}" title="{{.Title}}" style="left: {{printf "%.3f" .Left}}%; top: {{printf "%.3f" .Top}}%; width: {{printf "%.3f" .Width}}%; height: {{printf "%.3f" .Height}}%; background: hsl({{.Hue}}, 60%, {{if .Package}}70%{{else}}85%{{end}});">{{.Label}}</div>
+{{- end}}
+</div>
+<details>
+<summary>Modules</summary>
+<table>
+<tr><th>Module</th><th>Package</th><th>Size</th><th>Imported via</th></tr>
+{{- range .Rows}}
+<tr><td>{{.Path}}</td><td>{{.Package}}</td><td class="size">{{size .Bytes}}</td><td class="chain">{{join .Chain " → "}}</td></tr>
+{{- end}}
+</table>
+</details>
+</section>
+{{end}}
+</body>
+</html>
+`))
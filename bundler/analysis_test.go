@@ -0,0 +1,167 @@
+package bundler
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestNewBundleAnalysis(t *testing.T) {
+	root := filepath.FromSlash("/project")
+	outdir := filepath.Join(root, "dist")
+	records := []BuildRecord{{
+		Input: "/src/main.js",
+		Result: api.BuildResult{Metafile: `{
+			"inputs": {
+				"src/main.js": {"imports": [{"path": "src/a.js"}, {"path": "https://cdn/x.js", "external": true}]},
+				"src/a.js": {"imports": [{"path": "node_modules/@scope/pkg/index.js"}, {"path": "src/main.js"}]},
+				"node_modules/@scope/pkg/index.js": {"imports": [{"path": "node_modules/lodash/add.js"}]},
+				"node_modules/lodash/add.js": {}
+			},
+			"outputs": {
+				"dist/main.js": {"bytes": 1000, "entryPoint": "src/main.js", "inputs": {
+					"src/main.js": {"bytesInOutput": 100},
+					"src/a.js": {"bytesInOutput": 300},
+					"node_modules/@scope/pkg/index.js": {"bytesInOutput": 400},
+					"node_modules/lodash/add.js": {"bytesInOutput": 200},
+					"src/types.js": {"bytesInOutput": 0}
+				}},
+				"dist/main.js.map": {"bytes": 5000, "inputs": {}}
+			}
+		}`},
+		Output: &BuildResult{Source: "src/main.js", Entry: filepath.Join(outdir, "main.js")},
+	}}
+
+	analyses, err := NewBundleAnalysis(records, root, outdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(analyses) != 1 {
+		t.Fatalf("got %d analyses, want 1 for the output containing modules", len(analyses))
+	}
+	analysis := analyses[0]
+	if analysis.Input != "/src/main.js" || analysis.Output != "main.js" || analysis.Bytes != 1000 {
+		t.Errorf("got %s built from %s with %d bytes", analysis.Output, analysis.Input, analysis.Bytes)
+	}
+
+	want := []ModuleAnalysis{
+		{"@scope/pkg", "node_modules/@scope/pkg/index.js", 400, []string{"src/main.js", "src/a.js", "node_modules/@scope/pkg/index.js"}},
+		{"(project)", "src/a.js", 300, []string{"src/main.js", "src/a.js"}},
+		{"lodash", "node_modules/lodash/add.js", 200, []string{"src/main.js", "src/a.js", "node_modules/@scope/pkg/index.js", "node_modules/lodash/add.js"}},
+		{"(project)", "src/main.js", 100, []string{"src/main.js"}},
+	}
+	if !reflect.DeepEqual(analysis.Rows, want) {
+		t.Errorf("got rows %+v, want %+v", analysis.Rows, want)
+	}
+
+	// the packages cover the canvas in proportion to their sizes, and the modules lie within their packages
+	var packages, modules int
+	for _, node := range analysis.Nodes {
+		if node.Package {
+			packages++
+			var bytes int
+			for _, row := range want {
+				if row.Package == node.Label {
+					bytes += row.Bytes
+				}
+			}
+			if got, want := node.Width*node.Height, 100*100*float64(bytes)/1000; math.Abs(got-want) > 1e-6 {
+				t.Errorf("got area %f for %s, want %f", got, node.Label, want)
+			}
+		} else {
+			modules++
+		}
+	}
+	if packages != 3 || modules != 4 {
+		t.Errorf("got %d packages and %d modules, want 3 and 4", packages, modules)
+	}
+}
+
+func TestPackageName(t *testing.T) {
+	tcs := []struct {
+		path, want string
+	}{
+		{"src/main.js", "(project)"},
+		{"node_modules/lodash/add.js", "lodash"},
+		{"node_modules/@scope/pkg/lib/index.js", "@scope/pkg"},
+		{"node_modules/a/node_modules/b/index.js", "b"},
+		{"node_modules/@scope", "@scope"},
+	}
+	for _, tc := range tcs {
+		if got := packageName(tc.path); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestSquarify(t *testing.T) {
+	tcs := []struct {
+		name  string
+		sizes []float64
+		r     treemapRect
+	}{
+		{"single", []float64{1}, treemapRect{0, 0, 1200, 600}},
+		{"wide", []float64{6, 6, 4, 3, 2, 2, 1}, treemapRect{0, 0, 1200, 600}},
+		{"tall", []float64{5, 3, 1, 1}, treemapRect{10, 20, 100, 400}},
+		{"zero", []float64{0, 0}, treemapRect{0, 0, 100, 100}},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			rects := squarify(tc.sizes, tc.r)
+			if len(rects) != len(tc.sizes) {
+				t.Fatalf("got %d rectangles, want %d", len(rects), len(tc.sizes))
+			}
+			var total float64
+			for _, size := range tc.sizes {
+				total += size
+			}
+			for i, r := range rects {
+				want := 0.0
+				if total > 0 {
+					want = tc.sizes[i] / total * tc.r.w * tc.r.h
+				}
+				if math.Abs(r.w*r.h-want) > 1e-6 {
+					t.Errorf("rectangle %d: got area %f, want %f", i, r.w*r.h, want)
+				}
+				if total > 0 && (r.x < tc.r.x-1e-6 || r.y < tc.r.y-1e-6 ||
+					r.x+r.w > tc.r.x+tc.r.w+1e-6 || r.y+r.h > tc.r.y+tc.r.h+1e-6) {
+					t.Errorf("rectangle %d: got %+v outside %+v", i, r, tc.r)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteBundleAnalysis(t *testing.T) {
+	rows := []ModuleAnalysis{
+		{"(project)", "src/<main>.js", 300, []string{"src/<main>.js"}},
+		{"lodash", "node_modules/lodash/add.js", 100, []string{"src/<main>.js", "node_modules/lodash/add.js"}},
+	}
+	analyses := []BundleAnalysis{{Input: "/src/<main>.js", Output: "main.js", Bytes: 400, Nodes: layoutTreemap(rows), Rows: rows}}
+
+	var b strings.Builder
+	if err := WriteBundleAnalysis(&b, analyses); err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+	if strings.Contains(got, "ZgotmplZ") {
+		t.Errorf("got values rejected by the template in\n%s", got)
+	}
+	// the report is self-contained
+	for _, s := range []string{"src=", "href=", "url(", "@import", "http:", "https:"} {
+		if strings.Contains(got, s) {
+			t.Errorf("got an external reference %s in\n%s", s, got)
+		}
+	}
+	if !strings.Contains(got, "src/&lt;main&gt;.js") || strings.Contains(got, "<main>") {
+		t.Errorf("got unescaped module paths in\n%s", got)
+	}
+	if !strings.Contains(got, "hsl(") || !strings.Contains(got, "left: 0.000%") {
+		t.Errorf("got no treemap styles in\n%s", got)
+	}
+}
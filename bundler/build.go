@@ -0,0 +1,509 @@
+// Package bundler builds the scripts, stylesheets and assets referenced by HTML documents with esbuild and rewrites
+// the documents to reference the outputs.
+package bundler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+
+	"github.com/dlw93/cvbuild/util"
+)
+
+// A BuildMode selects between optimized production builds and readable development builds.
+type BuildMode uint
+
+const (
+	BuildModeProduction BuildMode = iota
+	BuildModeDevelopment
+)
+
+var buildModeNames = []string{"production", "development"}
+
+type BuildOptions struct {
+	Mode                BuildMode
+	OutputDirectory     string
+	ProjectRootAbsolute string
+	Aliases             []PathAlias
+	External            []string
+	Loaders             map[string]api.Loader
+	JSX                 JSXOptions
+	Tsconfig            string
+	Define              map[string]string
+
+	// Splitting moves code the entry point shares with its dynamic imports into chunks, which the entry output imports
+	// statically. It must only be set for entry points loaded as modules; nested worker builds never split.
+	Splitting bool
+
+	// OnResult, if set, is called with the result of every build, including failed and nested worker builds.
+	OnResult ResultHandler
+
+	// OnDependency, if set, is called with every new URL(..., import.meta.url) dependency of the bundled scripts and
+	// the path of the script it was found in, relative to the project root.
+	OnDependency func(importer string, dep Dependency)
+
+	workers   *workerBuilds // shared by the nested worker builds of an entry point
+	entryName string        // the path of the entry output relative to OutputDirectory without extension, if set
+}
+
+// workerBuilds tracks the workers built for an entry point, so that workers spawning themselves or each other are
+// built once instead of recursing forever.
+type workerBuilds struct {
+	mu      sync.Mutex
+	outputs map[string]string // maps worker scripts to their known or predicted outputs
+}
+
+// start reports whether the worker at path has been built or is being built, and if so returns its output.
+// Otherwise it records that the worker is being built and will be written to out.
+func (w *workerBuilds) start(path, out string) (string, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if known, ok := w.outputs[path]; ok {
+		return known, true
+	}
+	w.outputs[path] = out
+	return "", false
+}
+
+func (w *workerBuilds) finish(path, out string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.outputs[path] = out
+}
+
+// A ResultHandler receives the record of a build.
+type ResultHandler func(record BuildRecord)
+
+// JSXOptions configures how JSX syntax is transformed; zero values select esbuild's defaults.
+type JSXOptions struct {
+	Mode         api.JSX
+	Dev          bool
+	Factory      string
+	Fragment     string
+	ImportSource string
+}
+
+type BuildError api.Message
+
+// A WorkerBuilder bundles the worker script at the given path relative to the project root and returns the path of its output.
+type WorkerBuilder func(path string) (string, error)
+
+// ImportMetaUrlPlugin scans all files whose extension is mapped to a script loader for new URL(..., import.meta.url) expressions.
+// Scripts passed to new Worker or new SharedWorker are bundled by buildWorker, which is called for every reference and
+// should return known outputs for workers it has built before. Their URLs are rewritten to the outputs, relative to
+// the entry output of the build.
+// If onDependency is not nil, it is called with every dependency found and the path of its script relative to the root.
+func ImportMetaUrlPlugin(loaders map[string]api.Loader, buildWorker WorkerBuilder, onDependency func(string, Dependency)) api.Plugin {
+	var exts []string
+	for ext, loader := range loaders {
+		if isScriptLoader(loader) {
+			exts = append(exts, regexp.QuoteMeta(ext))
+		}
+	}
+	sort.Strings(exts)
+
+	return api.Plugin{
+		Name: "import-meta-url",
+		Setup: func(build api.PluginBuild) {
+			if len(exts) == 0 {
+				return
+			}
+
+			filter := "(?:" + strings.Join(exts, "|") + ")$"
+			root := filepath.Clean(build.InitialOptions.AbsWorkingDir)
+			outdir := build.InitialOptions.Outdir
+			if !filepath.IsAbs(outdir) {
+				outdir = filepath.Join(root, outdir)
+			}
+			// the scripts are bundled into the entry output, so URLs are relative to its directory
+			entryDir := outdir
+			if entries := build.InitialOptions.EntryPointsAdvanced; len(entries) == 1 && entries[0].OutputPath != "" {
+				entryDir = filepath.Dir(filepath.Join(outdir, entries[0].OutputPath))
+			}
+
+			outputURL := func(path string) (string, error) {
+				rel, err := filepath.Rel(root, path)
+				if err != nil {
+					return "", err
+				}
+				out, err := buildWorker("/" + filepath.ToSlash(rel))
+				if err != nil {
+					return "", err
+				}
+				return relativeURL(entryDir, out)
+			}
+
+			build.OnLoad(api.OnLoadOptions{Filter: filter}, func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+				b, err := os.ReadFile(args.Path)
+				if err != nil {
+					return api.OnLoadResult{}, err
+				}
+				s := NewDependencyScanner(b)
+				dir := filepath.Dir(args.Path)
+				importer, err := filepath.Rel(root, args.Path)
+				if err != nil {
+					return api.OnLoadResult{}, err
+				}
+
+				var files []string
+				var warnings []api.Message
+				var contents strings.Builder
+				last := 0
+				for _, dep := range s.Scan() {
+					if dep.Dynamic {
+						text := fmt.Sprintf("The path %q cannot be determined at build time and is left as is", dep.Path)
+						warnings = append(warnings, api.Message{Text: text, Location: s.location(dep, root, args.Path)})
+						continue
+					}
+					path := filepath.Join(dir, dep.Path)
+					files = append(files, path)
+					if onDependency != nil {
+						onDependency(filepath.ToSlash(importer), dep)
+					}
+
+					if dep.Kind == DependencyWorker || dep.Kind == DependencySharedWorker {
+						url, err := outputURL(path)
+						if err != nil {
+							return api.OnLoadResult{}, fmt.Errorf("failed to build worker %s: %w", dep.Path, err)
+						}
+						contents.Write(b[last:dep.Start])
+						contents.WriteString(strconv.Quote(url))
+						last = dep.End
+					}
+				}
+
+				var text string
+				if last == 0 {
+					text = s.String()
+				} else {
+					contents.Write(b[last:])
+					text = contents.String()
+				}
+
+				return api.OnLoadResult{
+					Contents:   &text,
+					ResolveDir: dir,
+					Loader:     loaders[filepath.Ext(args.Path)],
+					WatchFiles: files,
+					Warnings:   warnings,
+				}, nil
+			})
+		},
+	}
+}
+
+func AbsolutePathPlugin() api.Plugin {
+	return api.Plugin{
+		Name: "absolute-path",
+		Setup: func(build api.PluginBuild) {
+			root := filepath.Clean(build.InitialOptions.AbsWorkingDir)
+
+			build.OnResolve(api.OnResolveOptions{Filter: "^/"}, func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+				result, err := resolveAbsolute(build, filepath.Join(root, args.Path), args)
+				if err != nil {
+					err := fmt.Errorf("failed to resolve %s in %s: %w", args.Path, root, err)
+					return api.OnResolveResult{}, err
+				}
+
+				errs := newBuildError(result.Errors)
+
+				return api.OnResolveResult{
+					Path:      result.Path,
+					Namespace: args.Namespace,
+				}, errs
+			})
+		},
+	}
+}
+
+// PathAliasPlugin resolves import paths matching one of the given aliases to the alias targets.
+// Targets are tried in order; if none of them resolves, resolution is left to the next resolver.
+// esbuild applies the paths of tsconfig.json to imports in scripts by itself; the plugin exists for the references
+// in documents, which are built as entry points, and for the aliases of the configuration file.
+func PathAliasPlugin(aliases []PathAlias) api.Plugin {
+	aliases = append([]PathAlias(nil), aliases...)
+	sortPathAliases(aliases)
+
+	return api.Plugin{
+		Name: "path-alias",
+		Setup: func(build api.PluginBuild) {
+			if len(aliases) == 0 {
+				return
+			}
+
+			prefixes := util.Map(aliases, func(a PathAlias) string { return regexp.QuoteMeta(a.prefix()) })
+			filter := "^(?:" + strings.Join(prefixes, "|") + ")"
+
+			build.OnResolve(api.OnResolveOptions{Filter: filter}, func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+				for _, alias := range aliases {
+					targets, ok := alias.Match(args.Path)
+					if !ok {
+						continue
+					}
+					for _, target := range targets {
+						if result, err := resolveAbsolute(build, target, args); err == nil && len(result.Errors) == 0 {
+							return api.OnResolveResult{
+								Path:      result.Path,
+								Namespace: args.Namespace,
+							}, nil
+						}
+					}
+					break
+				}
+				return api.OnResolveResult{}, nil
+			})
+		},
+	}
+}
+
+// resolveAbsolute resolves the absolute path as if it had been imported relative to args.ResolveDir.
+func resolveAbsolute(build api.PluginBuild, path string, args api.OnResolveArgs) (api.ResolveResult, error) {
+	rel, err := filepath.Rel(args.ResolveDir, path)
+	if err != nil {
+		return api.ResolveResult{}, err
+	}
+
+	options := api.ResolveOptions{
+		ResolveDir: args.ResolveDir,
+		Kind:       args.Kind,
+		Importer:   args.Importer,
+		Namespace:  args.Namespace,
+	}
+
+	return build.Resolve("./"+filepath.ToSlash(rel), options), nil
+}
+
+// A BuildResult lists the files emitted by the build of an entry point by their absolute paths.
+type BuildResult struct {
+	// Source is the entry point relative to the project root, with forward slashes.
+	Source string
+
+	// Entry is the output that corresponds to the entry point.
+	Entry string
+
+	// CSSBundle is the CSS imported by the entry point and its dependencies, if any.
+	CSSBundle string
+
+	// Files lists all outputs but Entry, such as the CSS bundle and assets.
+	Files []string
+}
+
+// Build bundles the entry point at input, a path relative to the project root, and writes its outputs to the output
+// directory. The entry output is identified via the metafile rather than by the order of the outputs.
+// If ctx is done before the build completes, the build is cancelled and ctx.Err() is returned.
+func Build(ctx context.Context, input string, options BuildOptions) (*BuildResult, error) {
+	loaders := options.Loaders
+	if ext := filepath.Ext(input); loaders[ext] == api.LoaderFile {
+		// an asset referenced directly is copied as is instead of being turned into a module exporting its URL
+		loaders = make(map[string]api.Loader, len(options.Loaders))
+		for ext, loader := range options.Loaders {
+			loaders[ext] = loader
+		}
+		loaders[ext] = api.LoaderCopy
+	}
+
+	if options.workers == nil {
+		options.workers = &workerBuilds{outputs: make(map[string]string)}
+	}
+	entryName := options.entryName
+	outdir := options.OutputDirectory
+	if !filepath.IsAbs(outdir) {
+		outdir = filepath.Join(options.ProjectRootAbsolute, outdir)
+	}
+
+	opts := api.BuildOptions{
+		EntryPointsAdvanced: []api.EntryPoint{{InputPath: input, OutputPath: entryName}},
+		AbsWorkingDir:       options.ProjectRootAbsolute,
+
+		Bundle:            true,
+		MinifyWhitespace:  options.Mode == BuildModeProduction,
+		MinifyIdentifiers: options.Mode == BuildModeProduction,
+		MinifySyntax:      options.Mode == BuildModeProduction,
+
+		Outdir:     options.OutputDirectory,
+		Write:      true,
+		Metafile:   true,
+		Format:     api.FormatESModule,
+		Splitting:  options.Splitting,
+		External:   options.External,
+		Define:     options.Define,
+		Loader:     loaders,
+		EntryNames: "[name]",
+		ChunkNames: "chunks/[name]-[hash]",
+		AssetNames: "[name]",
+
+		Tsconfig:        options.Tsconfig,
+		JSX:             options.JSX.Mode,
+		JSXDev:          options.JSX.Dev,
+		JSXFactory:      options.JSX.Factory,
+		JSXFragment:     options.JSX.Fragment,
+		JSXImportSource: options.JSX.ImportSource,
+
+		Plugins: []api.Plugin{
+			AbsolutePathPlugin(),
+			PathAliasPlugin(options.Aliases),
+			ImportMetaUrlPlugin(loaders, func(path string) (string, error) {
+				// workers keep their path relative to the project root, so that workers of the same name don't
+				// overwrite each other, and a worker already being built, e.g. by a worker spawning itself, is
+				// known to be written there
+				name := strings.TrimSuffix(strings.TrimPrefix(path, "/"), filepath.Ext(path))
+				if !filepath.IsLocal(name) {
+					return "", fmt.Errorf("worker %s is outside the project root", path)
+				}
+				if out, ok := options.workers.start(path, filepath.Join(outdir, name+".js")); ok {
+					return out, nil
+				}
+				options := options
+				options.entryName = name
+				options.Splitting = false // classic workers cannot import chunks
+				result, err := Build(ctx, path, options)
+				if err != nil {
+					return "", err
+				}
+				options.workers.finish(path, result.Entry)
+				return result.Entry, nil
+			}, options.OnDependency),
+		},
+	}
+	if entryName != "" {
+		opts.EntryNames = "[dir]/[name]" // keeps the directory of entryName
+	}
+	start := time.Now()
+	result, err := build(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	record := BuildRecord{Input: input, Result: result, Duration: time.Since(start)}
+	if len(result.Errors) > 0 {
+		err = newBuildError(result.Errors)
+	} else if len(result.OutputFiles) == 0 {
+		err = fmt.Errorf("no output generated for %s", input)
+	} else {
+		record.Output, err = newBuildResult(result, options.ProjectRootAbsolute)
+	}
+
+	if options.OnResult != nil {
+		options.OnResult(record)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return record.Output, nil
+}
+
+// newBuildResult identifies the entry output of a successful build via its metafile, given the project root.
+// Chunks loaded by dynamic imports are entry points as well, but unlike the entry output, other outputs import them.
+// Entry points copied as is are not marked as such in the metafile, so the first output is assumed for them.
+func newBuildResult(result api.BuildResult, root string) (*BuildResult, error) {
+	meta, err := ParseMetafile(result.Metafile)
+	if err != nil {
+		return nil, err
+	}
+	imported := make(map[string]bool)
+	for _, output := range meta.Outputs {
+		for _, imp := range output.Imports {
+			imported[imp.Path] = true
+		}
+	}
+
+	r := &BuildResult{Entry: result.OutputFiles[0].Path}
+	for path, output := range meta.Outputs {
+		if output.EntryPoint != "" && !imported[path] {
+			r.Source = output.EntryPoint
+			r.Entry = filepath.Join(root, path)
+			if output.CSSBundle != "" {
+				r.CSSBundle = filepath.Join(root, output.CSSBundle)
+			}
+			break
+		}
+	}
+	if r.Source == "" {
+		if rel, err := filepath.Rel(root, r.Entry); err == nil {
+			for input := range meta.Outputs[filepath.ToSlash(rel)].Inputs {
+				r.Source = input
+			}
+		}
+	}
+	for _, file := range result.OutputFiles {
+		if file.Path != r.Entry {
+			r.Files = append(r.Files, file.Path)
+		}
+	}
+	return r, nil
+}
+
+func (m BuildMode) String() string {
+	if int(m) < len(buildModeNames) {
+		return buildModeNames[m]
+	}
+	return fmt.Sprintf("BuildMode(%d)", uint(m))
+}
+
+// Set parses the name of a build mode, implementing flag.Value.
+func (m *BuildMode) Set(s string) error {
+	for i, name := range buildModeNames {
+		if name == s {
+			*m = BuildMode(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown build mode %q, want one of %s", s, strings.Join(buildModeNames, ", "))
+}
+
+// build runs an esbuild build with the given options like api.Build, but cancels it when ctx is done.
+func build(ctx context.Context, opts api.BuildOptions) (api.BuildResult, error) {
+	if err := ctx.Err(); err != nil {
+		return api.BuildResult{}, err
+	}
+	esbuild, ctxErr := api.Context(opts)
+	if ctxErr != nil {
+		return api.BuildResult{Errors: ctxErr.Errors}, nil
+	}
+	defer esbuild.Dispose()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			esbuild.Cancel()
+		case <-done:
+		}
+	}()
+
+	result := esbuild.Rebuild()
+	if err := ctx.Err(); err != nil {
+		return api.BuildResult{}, err
+	}
+	return result, nil
+}
+
+func newBuildError[T api.Message | []api.Message](msg T) error {
+	switch msg := any(msg).(type) {
+	case api.Message:
+		return BuildError(msg)
+	case []api.Message:
+		return errors.Join(util.Map(msg, newBuildError[api.Message])...)
+	default:
+		panic("unreachable")
+	}
+}
+
+func (msg BuildError) Error() string {
+	if msg.Location != nil {
+		return fmt.Sprintf("%s [Ln %d, Col %d]: %s", msg.Location.File, msg.Location.Line, msg.Location.Column, msg.Text)
+	} else {
+		return msg.Text
+	}
+}
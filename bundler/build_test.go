@@ -0,0 +1,167 @@
+package bundler
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// writeFiles writes the files, given by their paths relative to dir, and returns dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) string {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestBuildWorkerCycles(t *testing.T) {
+	tcs := []struct {
+		name  string
+		files map[string]string
+		input string
+		want  map[string][]string // maps outputs to the URLs they must reference
+	}{
+		{
+			"self-spawning worker",
+			map[string]string{
+				"main.js":   "new Worker(new URL('./worker.js', import.meta.url));",
+				"worker.js": "new Worker(new URL('./worker.js', import.meta.url));",
+			},
+			"/main.js",
+			map[string][]string{"main.js": {"./worker.js"}, "worker.js": {"./worker.js"}},
+		},
+		{
+			"workers spawning each other",
+			map[string]string{
+				"main.js": "new Worker(new URL('./a.js', import.meta.url));",
+				"a.js":    "new Worker(new URL('./b.js', import.meta.url));",
+				"b.js":    "new Worker(new URL('./a.js', import.meta.url));",
+			},
+			"/main.js",
+			map[string][]string{"main.js": {"./a.js"}, "a.js": {"./b.js"}, "b.js": {"./a.js"}},
+		},
+		{
+			"workers of the same name",
+			map[string]string{
+				"main.js": "new Worker(new URL('./a/worker.js', import.meta.url));\n" +
+					"new Worker(new URL('./b/worker.js', import.meta.url));",
+				"a/worker.js": "new Worker(new URL('../b/worker.js', import.meta.url));",
+				"b/worker.js": "console.log('b');",
+			},
+			"/main.js",
+			map[string][]string{"main.js": {"./a/worker.js", "./b/worker.js"}, "a/worker.js": {"../b/worker.js"}},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			root := writeFiles(t, t.TempDir(), tc.files)
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			options := BuildOptions{
+				Mode:                BuildModeDevelopment,
+				OutputDirectory:     "dist",
+				ProjectRootAbsolute: root,
+				Loaders:             DefaultLoaders,
+			}
+			if _, err := Build(ctx, tc.input, options); err != nil {
+				t.Fatal(err)
+			}
+			for output, urls := range tc.want {
+				b, err := os.ReadFile(filepath.Join(root, "dist", filepath.FromSlash(output)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, url := range urls {
+					if !strings.Contains(string(b), `"`+url+`"`) {
+						t.Errorf("%s does not reference %s:\n%s", output, url, b)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestBuildAssets(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n"
+	root := writeFiles(t, t.TempDir(), map[string]string{
+		"logo.png": png,
+		"main.js":  "import logo from './logo.png'; console.log(logo);",
+	})
+	options := BuildOptions{
+		Mode:                BuildModeDevelopment,
+		OutputDirectory:     "dist",
+		ProjectRootAbsolute: root,
+		Loaders:             DefaultLoaders,
+	}
+	outdir := filepath.Join(root, "dist")
+
+	// a directly referenced asset is copied as is rather than turned into a module exporting its URL
+	result, err := Build(context.Background(), "/logo.png", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(outdir, "logo.png"); result.Entry != want || len(result.Files) != 0 {
+		t.Errorf("got entry %s and files %q, want only %s", result.Entry, result.Files, want)
+	}
+	if b, err := os.ReadFile(result.Entry); err != nil {
+		t.Fatal(err)
+	} else if string(b) != png {
+		t.Errorf("got %q, want %q", b, png)
+	}
+
+	// an asset imported by a script is still emitted by the file loader
+	result, err = Build(context.Background(), "/main.js", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(outdir, "logo.png")}; !slices.Equal(result.Files, want) {
+		t.Errorf("got files %q, want %q", result.Files, want)
+	}
+	if b, err := os.ReadFile(result.Entry); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(string(b), `"./logo.png"`) {
+		t.Errorf("got no URL of logo.png in\n%s", b)
+	}
+}
+
+func TestBuildEmptyModule(t *testing.T) {
+	root := writeFiles(t, t.TempDir(), map[string]string{"main.js": "import './empty.js';", "empty.js": ""})
+	options := BuildOptions{OutputDirectory: "dist", ProjectRootAbsolute: root, Loaders: DefaultLoaders}
+
+	if _, err := Build(context.Background(), "/main.js", options); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Build(context.Background(), "/empty.js", options); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuildCanceled(t *testing.T) {
+	root := writeFiles(t, t.TempDir(), map[string]string{"main.js": "console.log('main');"})
+	options := BuildOptions{OutputDirectory: "dist", ProjectRootAbsolute: root, Loaders: DefaultLoaders}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Build(ctx, "/main.js", options); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+	if _, err := os.Stat(filepath.Join(root, "dist", "main.js")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v, want no output", err)
+	}
+}
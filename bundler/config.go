@@ -0,0 +1,107 @@
+package bundler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Config holds the settings that are read from the JSON configuration file passed via -config.
+type Config struct {
+	// Aliases maps import prefixes to paths relative to the project root, e.g. "@/" to "src/".
+	Aliases map[string]string `json:"aliases"`
+
+	// Tsconfig is the path of the tsconfig.json or jsconfig.json file relative to the project root.
+	// If empty, either file is looked up in the project root.
+	Tsconfig string `json:"tsconfig"`
+
+	// Externals maps bare import specifiers that are excluded from bundles to the URL they are loaded from
+	// or to a file relative to the project root that is copied into the output directory.
+	// They are resolved by an import map that is merged into the document.
+	Externals map[string]string `json:"externals"`
+
+	// Loaders maps file extensions to esbuild loader names (js, jsx, ts, tsx, css, json, text, base64, dataurl,
+	// file, copy), overriding DefaultLoaders.
+	Loaders map[string]string `json:"loaders"`
+
+	// ServiceWorker, if set, enables the generation of a service worker that precaches all output files.
+	ServiceWorker *ServiceWorkerConfig `json:"serviceWorker"`
+
+	// Budgets limits the total size of the outputs of entry points, keyed by their path as referenced in the document.
+	Budgets map[string]Budget `json:"budgets"`
+
+	// Inline sets the size limits below which scripts, stylesheets and images are inlined into the document.
+	Inline InlineConfig `json:"inline"`
+}
+
+// InlineConfig holds the size limits for inlining; files are inlined if they are smaller. Zero disables inlining.
+type InlineConfig struct {
+	Scripts Size `json:"scripts"`
+	Styles  Size `json:"styles"`
+	Images  Size `json:"images"`
+}
+
+type ServiceWorkerConfig struct {
+	// Source is the path of a service worker relative to the project root, which is bundled with every
+	// occurrence of self.__PRECACHE_MANIFEST replaced by the precache manifest.
+	// If empty, a service worker that serves the precached files cache-first is generated.
+	Source string `json:"source"`
+
+	// Filename is the path of the service worker relative to the output directory, which must end in .js and stay
+	// inside it. It defaults to sw.js for a generated service worker and to the name of Source with a .js extension
+	// otherwise.
+	Filename string `json:"filename"`
+}
+
+// LoadConfig reads the configuration from the JSON file at path.
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(b, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// NewDocumentOptions returns the options for building the document at input in the project at root, an absolute
+// path, as configured by config: the loaders, externals, inlining limits, budgets and service worker of config, and
+// the path aliases of config and of the project's tsconfig.json or jsconfig.json along with its JSX settings.
+// The build mode, output directory and the other options are left for the caller to set.
+func NewDocumentOptions(config *Config, root, input string) (DocumentOptions, error) {
+	loaders, err := NewLoaders(config.Loaders)
+	if err != nil {
+		return DocumentOptions{}, fmt.Errorf("invalid loaders: %w", err)
+	}
+
+	options := DocumentOptions{
+		BuildOptions: BuildOptions{
+			ProjectRootAbsolute: root,
+			Aliases:             NewPathAliases(config.Aliases, root),
+			Loaders:             loaders,
+			Tsconfig:            config.Tsconfig,
+		},
+		Input:         input,
+		Externals:     config.Externals,
+		Inline:        config.Inline,
+		Budgets:       config.Budgets,
+		ServiceWorker: config.ServiceWorker,
+	}
+
+	path, ok := filepath.Join(root, config.Tsconfig), config.Tsconfig != ""
+	if !ok {
+		path, ok = FindTSConfig(root)
+	}
+	if ok {
+		tsconfig, err := ReadTSConfig(path)
+		if err != nil {
+			return DocumentOptions{}, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		options.Aliases = append(options.Aliases, tsconfig.PathAliases()...)
+		options.JSX = tsconfig.JSXOptions()
+	}
+	return options, nil
+}
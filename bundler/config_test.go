@@ -0,0 +1,94 @@
+package bundler
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"golang.org/x/exp/slices"
+)
+
+func TestNewDocumentOptions(t *testing.T) {
+	tcs := []struct {
+		name    string
+		files   map[string]string
+		config  Config
+		aliases []string // the patterns of the expected aliases, in order
+		jsx     api.JSX
+		wantErr bool
+	}{
+		{
+			"no tsconfig",
+			nil,
+			Config{Aliases: map[string]string{"@/": "src/"}},
+			[]string{"@/*"},
+			api.JSXTransform,
+			false,
+		},
+		{
+			"found tsconfig",
+			map[string]string{"tsconfig.json": `{"compilerOptions": {"paths": {"~/*": ["src/*"]}, "jsx": "react-jsx"}}`},
+			Config{Aliases: map[string]string{"@/": "src/"}},
+			[]string{"@/*", "~/*"},
+			api.JSXAutomatic,
+			false,
+		},
+		{
+			"configured tsconfig",
+			map[string]string{
+				"tsconfig.json":        `{"compilerOptions": {"paths": {"~/*": ["src/*"]}}}`,
+				"config/jsconfig.json": `{"compilerOptions": {"paths": {"#/*": ["../lib/*"]}, "jsx": "preserve"}}`,
+			},
+			Config{Tsconfig: "config/jsconfig.json"},
+			[]string{"#/*"},
+			api.JSXPreserve,
+			false,
+		},
+		{
+			"invalid tsconfig",
+			map[string]string{"tsconfig.json": `{"compilerOptions": [}`},
+			Config{},
+			nil,
+			api.JSXTransform,
+			true,
+		},
+		{
+			"invalid loader",
+			nil,
+			Config{Loaders: map[string]string{".png": "unknown"}},
+			nil,
+			api.JSXTransform,
+			true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			root := writeFiles(t, t.TempDir(), tc.files)
+			input := filepath.Join(root, "index.html")
+			options, err := NewDocumentOptions(&tc.config, root, input)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("got no error")
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if options.Input != input || options.ProjectRootAbsolute != root || options.Loaders[".ts"] != api.LoaderTS {
+				t.Errorf("got %+v, want the input, root and default loaders set", options)
+			}
+			var patterns []string
+			for _, alias := range options.Aliases {
+				patterns = append(patterns, alias.Pattern)
+			}
+			if !slices.Equal(patterns, tc.aliases) {
+				t.Errorf("got aliases %q, want %q", patterns, tc.aliases)
+			}
+			if options.JSX.Mode != tc.jsx {
+				t.Errorf("got JSX mode %d, want %d", options.JSX.Mode, tc.jsx)
+			}
+		})
+	}
+}
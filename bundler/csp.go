@@ -0,0 +1,256 @@
+package bundler
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// A CSPMode selects how the Content Security Policy of a document is emitted.
+type CSPMode uint
+
+const (
+	CSPModeOff CSPMode = iota
+	CSPModeMeta
+	CSPModeHeaders
+)
+
+var cspModeNames = []string{"off", "meta", "headers"}
+
+// A ContentSecurityPolicy maps directives to their source lists.
+type ContentSecurityPolicy map[string][]string
+
+// NewContentSecurityPolicy returns a policy that allows the document's own resources, the external resources it
+// references, its inline scripts and styles by their hashes, and the workers and WebAssembly modules among deps.
+func NewContentSecurityPolicy(doc *Document, deps []Dependency) ContentSecurityPolicy {
+	p := ContentSecurityPolicy{
+		"default-src": {"'self'"},
+		"script-src":  {"'self'"},
+		"style-src":   {"'self'"},
+	}
+
+	var visit func(*html.Node)
+	visit = func(node *html.Node) {
+		if node.Type == html.ElementNode && node.Namespace == "" {
+			switch node.DataAtom {
+			case atom.Script:
+				if src := getAttr(node, "src"); src != "" {
+					p.addReference("script-src", src)
+				} else if typ := strings.ToLower(getAttr(node, "type")); isExecutableScript(typ) {
+					p.add("script-src", inlineHash(doc.restoreText(textContent(node))))
+					if typ == "importmap" {
+						p.addImportMap(doc.restoreText(textContent(node)))
+					}
+				}
+			case atom.Style:
+				p.add("style-src", inlineHash(doc.restoreText(textContent(node))))
+			case atom.Link:
+				if directive, ok := linkDirective(node); ok {
+					p.addReference(directive, getAttr(node, "href"))
+				}
+			case atom.Img:
+				p.addReference("img-src", getAttr(node, "src"))
+			}
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(doc.root)
+
+	for _, dep := range deps {
+		switch {
+		case dep.Kind == DependencyWorker || dep.Kind == DependencySharedWorker:
+			p.add("worker-src", "'self'")
+		case strings.EqualFold(path.Ext(dep.Path), ".wasm"):
+			p.add("script-src", "'wasm-unsafe-eval'")
+		}
+	}
+	return p
+}
+
+// String formats the policy as the value of a Content-Security-Policy header.
+func (p ContentSecurityPolicy) String() string {
+	directives := make([]string, 0, len(p))
+	for directive := range p {
+		if directive != "default-src" {
+			directives = append(directives, directive)
+		}
+	}
+	sort.Strings(directives)
+	if _, ok := p["default-src"]; ok {
+		directives = append([]string{"default-src"}, directives...)
+	}
+
+	var b strings.Builder
+	for i, directive := range directives {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(directive)
+		for _, source := range p[directive] {
+			b.WriteString(" " + source)
+		}
+	}
+	return b.String()
+}
+
+// SetContentSecurityPolicy sets the policy of the document by a <meta http-equiv="Content-Security-Policy"> element,
+// which is inserted at the top of <head> unless the document already has one.
+func (d *Document) SetContentSecurityPolicy(p ContentSecurityPolicy) {
+	node := findElement(d.root, func(n *html.Node) bool {
+		return n.DataAtom == atom.Meta && strings.EqualFold(getAttr(n, "http-equiv"), "Content-Security-Policy")
+	})
+	if node == nil {
+		node = &html.Node{
+			Type:     html.ElementNode,
+			Data:     "meta",
+			DataAtom: atom.Meta,
+			Attr:     []html.Attribute{{Key: "http-equiv", Val: "Content-Security-Policy"}, {Key: "content"}},
+		}
+		head := d.head()
+		head.InsertBefore(node, head.FirstChild)
+	}
+	for i, attr := range node.Attr {
+		if attr.Key == "content" {
+			node.Attr[i].Val = p.String()
+			return
+		}
+	}
+	node.Attr = append(node.Attr, html.Attribute{Key: "content", Val: p.String()})
+}
+
+// WriteHeadersFile writes a _headers file to path that serves the document at url with the policy.
+func WriteHeadersFile(path, url string, p ContentSecurityPolicy) error {
+	return os.WriteFile(path, []byte(fmt.Sprintf("%s\n  Content-Security-Policy: %s\n", url, p)), 0o644)
+}
+
+// add adds source to the directive. A directive not in the policy yet starts out with the sources of default-src,
+// which it would otherwise fall back to, so that adding a source never revokes what default-src allowed.
+func (p ContentSecurityPolicy) add(directive, source string) {
+	if _, ok := p[directive]; !ok {
+		p[directive] = append([]string(nil), p["default-src"]...)
+	}
+	for _, s := range p[directive] {
+		if s == source {
+			return
+		}
+	}
+	p[directive] = append(p[directive], source)
+}
+
+// addReference adds the source expression matching the external resource referenced by ref, if any.
+func (p ContentSecurityPolicy) addReference(directive, ref string) {
+	if !isExternal(ref) {
+		return
+	}
+	if strings.HasPrefix(ref, "//") {
+		if u, err := url.Parse("https:" + ref); err == nil {
+			p.add(directive, u.Host)
+		}
+		return
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return
+	}
+	switch u.Scheme {
+	case "http", "https":
+		p.add(directive, u.Scheme+"://"+u.Host)
+	case "data", "blob":
+		p.add(directive, u.Scheme+":")
+	}
+}
+
+// addImportMap adds the origins of the modules mapped by the import map to script-src.
+func (p ContentSecurityPolicy) addImportMap(text string) {
+	var m ImportMap
+	if err := json.Unmarshal([]byte(text), &m); err != nil {
+		return
+	}
+	for _, target := range m.Imports {
+		p.addReference("script-src", target)
+	}
+	for _, imports := range m.Scopes {
+		for _, target := range imports {
+			p.addReference("script-src", target)
+		}
+	}
+}
+
+// linkDirective returns the directive governing the resource of a <link> element, if it loads one.
+func linkDirective(node *html.Node) (string, bool) {
+	for _, rel := range strings.Fields(strings.ToLower(getAttr(node, "rel"))) {
+		switch rel {
+		case "stylesheet":
+			return "style-src", true
+		case "modulepreload":
+			return "script-src", true
+		case "icon", "apple-touch-icon":
+			return "img-src", true
+		case "manifest":
+			return "manifest-src", true
+		case "preload":
+			switch strings.ToLower(getAttr(node, "as")) {
+			case "script":
+				return "script-src", true
+			case "style":
+				return "style-src", true
+			case "font":
+				return "font-src", true
+			case "image":
+				return "img-src", true
+			}
+		}
+	}
+	return "", false
+}
+
+// isExecutableScript reports whether a <script> of the given type is subject to script-src, unlike data blocks.
+func isExecutableScript(typ string) bool {
+	switch typ {
+	case "", "module", "importmap", "text/javascript", "application/javascript":
+		return true
+	}
+	return false
+}
+
+func inlineHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+}
+
+// restoreText restores the template blocks in text taken from the document.
+func (d *Document) restoreText(text string) string {
+	if d.templates == nil {
+		return text
+	}
+	return string(d.templates.restore([]byte(text)))
+}
+
+func (m CSPMode) String() string {
+	if int(m) < len(cspModeNames) {
+		return cspModeNames[m]
+	}
+	return fmt.Sprintf("CSPMode(%d)", uint(m))
+}
+
+// Set parses the name of a CSP mode, implementing flag.Value.
+func (m *CSPMode) Set(s string) error {
+	for i, name := range cspModeNames {
+		if name == s {
+			*m = CSPMode(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown CSP mode %q, want one of %s", s, strings.Join(cspModeNames, ", "))
+}
@@ -0,0 +1,80 @@
+package bundler
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestNewContentSecurityPolicy(t *testing.T) {
+	hash := func(text string) string {
+		sum := sha256.Sum256([]byte(text))
+		return "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+	}
+
+	tcs := []struct {
+		name string
+		src  string
+		deps []Dependency
+		want string
+	}{
+		{
+			"own resources",
+			`<script src="main.js"></script><link rel=stylesheet href=style.css><img src=a.png>`,
+			nil,
+			"default-src 'self'; script-src 'self'; style-src 'self'",
+		},
+		{
+			"data image",
+			`<img src="data:image/png;base64,AAAA"><img src=a.png>`,
+			nil,
+			"default-src 'self'; img-src 'self' data:; script-src 'self'; style-src 'self'",
+		},
+		{
+			"protocol-relative font",
+			`<link rel=preload as=font href="//fonts.example.com/a.woff2" crossorigin>`,
+			nil,
+			"default-src 'self'; font-src 'self' fonts.example.com; script-src 'self'; style-src 'self'",
+		},
+		{
+			"external manifest",
+			`<link rel=manifest href="https://cdn.example.com/app.webmanifest">`,
+			nil,
+			"default-src 'self'; manifest-src 'self' https://cdn.example.com; script-src 'self'; style-src 'self'",
+		},
+		{
+			"import map",
+			`<script type=importmap>{"imports":{"a":"https://cdn.example.com/a.js"},` +
+				`"scopes":{"/x/":{"b":"http://other.example.com/b.js"}}}</script>`,
+			nil,
+			"default-src 'self'; script-src 'self' " +
+				hash(`{"imports":{"a":"https://cdn.example.com/a.js"},"scopes":{"/x/":{"b":"http://other.example.com/b.js"}}}`) +
+				" https://cdn.example.com http://other.example.com; style-src 'self'",
+		},
+		{
+			"inline hashes",
+			`<script>alert(1)</script><script type="application/ld+json">{}</script><style>p{color:red}</style>`,
+			nil,
+			"default-src 'self'; script-src 'self' " + hash("alert(1)") + "; style-src 'self' " + hash("p{color:red}"),
+		},
+		{
+			"workers and wasm",
+			`<script src="main.js"></script>`,
+			[]Dependency{{Path: "./worker.js", Kind: DependencyWorker}, {Path: "./lib.wasm"}},
+			"default-src 'self'; script-src 'self' 'wasm-unsafe-eval'; style-src 'self'; worker-src 'self'",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := NewDocument(strings.NewReader(tc.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := NewContentSecurityPolicy(doc, tc.deps).String(); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package bundler
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+type DependencyKind uint
+
+const (
+	// DependencyURL is a file referenced by a new URL(path, import.meta.url) expression.
+	DependencyURL DependencyKind = iota
+
+	// DependencyWorker is a script referenced by a new Worker(new URL(path, import.meta.url)) expression.
+	DependencyWorker
+
+	// DependencySharedWorker is a script referenced by a new SharedWorker(new URL(path, import.meta.url)) expression.
+	DependencySharedWorker
+)
+
+// A Dependency is a file referenced relative to import.meta.url.
+type Dependency struct {
+	Kind DependencyKind
+
+	// Path is the referenced path. If the path is built from template substitutions or variables,
+	// Dynamic is set and each of them is replaced by '*'.
+	Path    string
+	Dynamic bool
+
+	// Start and End delimit the path argument in the source text, including its quotes.
+	Start, End int
+
+	// Line (1-based) and Column (0-based, in bytes) locate Start in the source text.
+	Line, Column int
+}
+
+type DependencyScanner struct {
+	text []byte
+}
+
+func NewDependencyScanner(text []byte) *DependencyScanner {
+	return &DependencyScanner{text}
+}
+
+func (s *DependencyScanner) String() string {
+	if len(s.text) == 0 {
+		return ""
+	}
+	return unsafe.String(&s.text[0], len(s.text))
+}
+
+// Scan returns the dependencies referenced by new URL(path, import.meta.url) expressions in the source text.
+// Comments, string literals and regular expressions are skipped. The path may be a string or template literal
+// or a concatenation of literals and variables.
+func (s *DependencyScanner) Scan() []Dependency {
+	if !bytes.Contains(s.text, []byte("import.meta.url")) {
+		return nil
+	}
+
+	var deps []Dependency
+	tokens := tokenize(s.text)
+	for i := range tokens {
+		if !matchTokens(tokens[i:], "new", "URL", "(") {
+			continue
+		}
+		dep, ok := s.scanArguments(tokens[i+3:])
+		if !ok {
+			continue
+		}
+		switch {
+		case i >= 3 && matchTokens(tokens[i-3:], "new", "Worker", "("):
+			dep.Kind = DependencyWorker
+		case i >= 3 && matchTokens(tokens[i-3:], "new", "SharedWorker", "("):
+			dep.Kind = DependencySharedWorker
+		}
+		deps = append(deps, dep)
+	}
+	return deps
+}
+
+// scanArguments matches the arguments of a new URL(path, import.meta.url) expression.
+func (s *DependencyScanner) scanArguments(tokens []token) (Dependency, bool) {
+	var path strings.Builder
+	dep := Dependency{}
+
+	i := 0
+	for operand, first := true, true; ; operand = !operand {
+		if i >= len(tokens) {
+			return dep, false
+		}
+		tok := tokens[i]
+		if !operand {
+			if tok.kind != tokenPunctuator || tok.value != "+" {
+				break
+			}
+			i++
+			continue
+		}
+
+		switch tok.kind {
+		case tokenString, tokenTemplate:
+			path.WriteString(tok.value)
+			dep.Dynamic = dep.Dynamic || tok.dynamic
+			i++
+		case tokenIdentifier:
+			// a variable or member expression, e.g. config.path
+			for i++; matchTokens(tokens[i:], ".") && i+1 < len(tokens) && tokens[i+1].kind == tokenIdentifier; i += 2 {
+			}
+			path.WriteByte('*')
+			dep.Dynamic = true
+		default:
+			return dep, false
+		}
+		if first {
+			dep.Start, first = tok.start, false
+		}
+		dep.End = tokens[i-1].end
+	}
+
+	if !matchTokens(tokens[i:], ",", "import", ".", "meta", ".", "url") {
+		return dep, false
+	}
+	i += 6
+	if matchTokens(tokens[i:], ",") {
+		i++
+	}
+	if !matchTokens(tokens[i:], ")") {
+		return dep, false
+	}
+
+	dep.Path = path.String()
+	dep.Line = bytes.Count(s.text[:dep.Start], []byte("\n")) + 1
+	dep.Column = dep.Start - (bytes.LastIndexByte(s.text[:dep.Start], '\n') + 1)
+	return dep, true
+}
+
+// location returns the location of the dependency's path in the file at path for use in diagnostics.
+func (s *DependencyScanner) location(dep Dependency, root, path string) *api.Location {
+	if rel, err := filepath.Rel(root, path); err == nil {
+		path = filepath.ToSlash(rel)
+	}
+	beg := dep.Start - dep.Column
+	end := bytes.IndexByte(s.text[beg:], '\n')
+	if end < 0 {
+		end = len(s.text)
+	} else {
+		end += beg
+	}
+	return &api.Location{
+		File:     path,
+		Line:     dep.Line,
+		Column:   dep.Column,
+		Length:   dep.End - dep.Start,
+		LineText: strings.TrimSuffix(string(s.text[beg:end]), "\r"),
+	}
+}
+
+// matchTokens reports whether tokens start with identifiers or punctuators with the given values.
+func matchTokens(tokens []token, values ...string) bool {
+	if len(tokens) < len(values) {
+		return false
+	}
+	for i, value := range values {
+		if kind := tokens[i].kind; kind != tokenIdentifier && kind != tokenPunctuator || tokens[i].value != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package bundler
+
+import (
+	"testing"
+)
+
+func TestDependencyScannerScan(t *testing.T) {
+	tcs := []struct {
+		name string
+		src  string
+		want []Dependency
+	}{
+		{
+			"string literal",
+			`const u = new URL('./a.png', import.meta.url);`,
+			[]Dependency{{Path: "./a.png", Start: 18, End: 27, Line: 1, Column: 18}},
+		},
+		{
+			"comments and whitespace",
+			"new /* c */ URL(\n  \"./a.png\", // c\n  import.meta.url,\n)",
+			[]Dependency{{Path: "./a.png", Start: 19, End: 28, Line: 2, Column: 2}},
+		},
+		{
+			"line comment",
+			"// new URL('./a.png', import.meta.url)\n",
+			nil,
+		},
+		{
+			"block comment",
+			"/* new URL('./a.png', import.meta.url) */",
+			nil,
+		},
+		{
+			"string",
+			`const s = "new URL('./a.png', import.meta.url)";`,
+			nil,
+		},
+		{
+			"template",
+			"const s = `new URL('./a.png', import.meta.url)`;",
+			nil,
+		},
+		{
+			"regular expression",
+			`const re = /new URL('.\/a.png', import.meta.url)/;`,
+			nil,
+		},
+		{
+			"division",
+			`const x = a / 2, u = new URL("./a.png", import.meta.url) / 1;`,
+			[]Dependency{{Path: "./a.png", Start: 29, End: 38, Line: 1, Column: 29}},
+		},
+		{
+			"template with substitution",
+			"new URL(`./img/${name + `${x}`}.png`, import.meta.url)",
+			[]Dependency{{Path: "./img/*.png", Dynamic: true, Start: 8, End: 36, Line: 1, Column: 8}},
+		},
+		{
+			"concatenation",
+			`new URL("./img/" + "a.png", import.meta.url).href`,
+			[]Dependency{{Path: "./img/a.png", Start: 8, End: 26, Line: 1, Column: 8}},
+		},
+		{
+			"concatenation with variable",
+			`new URL("./img/" + config.name + ".png", import.meta.url)`,
+			[]Dependency{{Path: "./img/*.png", Dynamic: true, Start: 8, End: 39, Line: 1, Column: 8}},
+		},
+		{
+			"minified worker",
+			`let w=new Worker(new URL("./w.js",import.meta.url),{type:"module"});`,
+			[]Dependency{{Kind: DependencyWorker, Path: "./w.js", Start: 25, End: 33, Line: 1, Column: 25}},
+		},
+		{
+			"shared worker",
+			`new SharedWorker(new URL('./w.js', import.meta.url))`,
+			[]Dependency{{Kind: DependencySharedWorker, Path: "./w.js", Start: 25, End: 33, Line: 1, Column: 25}},
+		},
+		{
+			"other base",
+			`new URL('./a.png', location.href)`,
+			nil,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			got := NewDependencyScanner([]byte(tc.src)).Scan()
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("got %+v, want %+v", got[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDependencyScannerString(t *testing.T) {
+	for _, src := range []string{"", "console.log('a');"} {
+		if got := NewDependencyScanner([]byte(src)).String(); got != src {
+			t.Errorf("got %q, want %q", got, src)
+		}
+	}
+}
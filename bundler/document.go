@@ -0,0 +1,309 @@
+package bundler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/dlw93/cvbuild/util"
+)
+
+// DocumentOptions configures how BuildDocument builds an HTML document and what it emits besides the document.
+// BuildOptions.Splitting is ignored: it is set for the module scripts of the document only.
+type DocumentOptions struct {
+	BuildOptions
+
+	// Input is the path of the HTML document, which is written to the output directory under the same name.
+	Input string
+	Parse ParseOptions
+
+	// Externals maps bare import specifiers to URLs or files relative to the project root, as in NewImportMap.
+	Externals map[string]string
+	Inline    InlineConfig
+	Budgets   map[string]Budget
+
+	Preload     bool
+	Integrity   bool
+	CrossOrigin string // the crossorigin attribute added along with integrity attributes, if not empty
+	CSP         CSPMode
+
+	// PreserveFormatting rewrites the references in the original markup instead of re-rendering the document,
+	// which is minified in production mode.
+	PreserveFormatting bool
+
+	ServiceWorker *ServiceWorkerConfig
+	Report        bool   // measure the outputs, see DocumentResult.Sizes
+	Manifest      bool   // write a manifest.json to the output directory
+	Analyze       bool   // write a report.html to the output directory
+	Metafile      string // the path to write the merged metafile of all builds to, if not empty
+
+	// OnWalk, if set, is called with the error of building the dependencies of the document once all of them have
+	// been built. If it returns an error, BuildDocument stops and returns it; otherwise it continues if it can.
+	OnWalk func(err error) error
+}
+
+// A DocumentResult describes the outputs of BuildDocument.
+type DocumentResult struct {
+	// Output is the path of the written document.
+	Output string
+
+	// Records holds the records of all successful builds, including the service worker's.
+	Records []BuildRecord
+
+	// Sizes holds the sizes of all outputs if DocumentOptions.Report is set or budgets are given.
+	Sizes []OutputSize
+
+	// Files lists the document and the outputs of all builds.
+	Files []string
+}
+
+// BuildDocument builds the dependencies of the HTML document at options.Input, rewrites the document to reference
+// their outputs and writes it to the output directory, along with everything else options ask for.
+// It returns a result even if it fails, describing what was built up to the failure.
+func BuildDocument(ctx context.Context, options DocumentOptions) (*DocumentResult, error) {
+	recorder := &BuildRecorder{}
+	result := &DocumentResult{}
+	defer func() {
+		result.Records = recorder.Records()
+	}()
+
+	if onResult := options.OnResult; onResult != nil {
+		options.OnResult = func(record BuildRecord) {
+			recorder.Record(record)
+			onResult(record)
+		}
+	} else {
+		options.OnResult = recorder.Record
+	}
+	if onDependency := options.OnDependency; onDependency != nil {
+		options.OnDependency = func(importer string, dep Dependency) {
+			recorder.RecordDependency(importer, dep)
+			onDependency(importer, dep)
+		}
+	} else {
+		options.OnDependency = recorder.RecordDependency
+	}
+	options.External = append(append([]string(nil), options.External...), maps.Keys(options.Externals)...)
+	if !filepath.IsAbs(options.OutputDirectory) {
+		// as in Build, a relative output directory is relative to the project root
+		options.OutputDirectory = filepath.Join(options.ProjectRootAbsolute, options.OutputDirectory)
+	}
+	root, outdir := options.ProjectRootAbsolute, options.OutputDirectory
+
+	var importMap *ImportMap
+	if len(options.Externals) > 0 {
+		m, err := NewImportMap(options.Externals, root, outdir)
+		if err != nil {
+			return result, fmt.Errorf("failed to vendor externals: %w", err)
+		}
+		importMap = m
+	}
+
+	doc, err := parseDocumentFile(options.Input, options.Parse)
+	if err != nil {
+		return result, err
+	}
+
+	modules := doc.moduleScripts()
+	err = doc.Walk(ctx, func(ctx context.Context, path string) (string, error) {
+		options := options.BuildOptions
+		options.Splitting = modules[path]
+		built, err := Build(ctx, path, options)
+		if err != nil {
+			return "", err
+		}
+		return relativeURL(outdir, built.Entry)
+	})
+	if options.OnWalk != nil {
+		err = options.OnWalk(err)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	if options.Report || len(options.Budgets) > 0 {
+		if result.Sizes, err = NewSizeReport(recorder.Records(), root, outdir); err != nil {
+			return result, fmt.Errorf("failed to measure outputs: %w", err)
+		}
+		if errs := CheckBudgets(result.Sizes, options.Budgets); len(errs) > 0 {
+			return result, errors.Join(errs...)
+		}
+	}
+
+	if importMap != nil {
+		if err := doc.MergeImportMap(importMap); err != nil {
+			return result, fmt.Errorf("failed to merge import map into %s: %w", options.Input, err)
+		}
+	}
+
+	if err := doc.LinkCSSBundles(recorder.Records(), outdir); err != nil {
+		return result, fmt.Errorf("failed to link stylesheets in %s: %w", options.Input, err)
+	}
+
+	inlined, err := doc.Inline(outdir, options.Inline)
+	if err != nil {
+		return result, err
+	}
+	for _, path := range inlined {
+		// files imported by other outputs, like images referenced from stylesheets, are still needed
+		if imported, err := recorder.Imported(path, root); err != nil {
+			return result, fmt.Errorf("failed to read metafile: %w", err)
+		} else if !imported {
+			if err := os.Remove(path); err != nil {
+				return result, fmt.Errorf("failed to remove inlined file %s: %w", path, err)
+			}
+			recorder.Discard(path)
+		}
+	}
+
+	if options.Preload {
+		if err := doc.AddPreloadHints(recorder.Records(), root, outdir); err != nil {
+			return result, fmt.Errorf("failed to add preload hints to %s: %w", options.Input, err)
+		}
+	}
+
+	if options.Integrity {
+		if err := doc.AddIntegrity(outdir, options.CrossOrigin); err != nil {
+			return result, err
+		}
+	}
+
+	var csp ContentSecurityPolicy
+	if options.CSP != CSPModeOff {
+		csp = NewContentSecurityPolicy(doc, recorder.Dependencies())
+		if options.CSP == CSPModeMeta {
+			doc.SetContentSecurityPolicy(csp)
+		}
+	}
+
+	result.Output = filepath.Join(outdir, filepath.Base(options.Input))
+	if err := os.MkdirAll(outdir, 0o755); err != nil {
+		return result, err
+	}
+	err = writeFile(result.Output, func(w io.Writer) (err error) {
+		if options.PreserveFormatting {
+			_, err = doc.WriteSourceTo(w)
+		} else if options.Mode == BuildModeProduction {
+			_, err = doc.WriteMinifiedTo(w)
+		} else {
+			_, err = doc.WriteTo(w)
+		}
+		return err
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to write to %s: %w", result.Output, err)
+	}
+	result.Files = append(recorder.Outputs(), result.Output)
+
+	if options.CSP == CSPModeHeaders {
+		path := filepath.Join(outdir, "_headers")
+		if err := WriteHeadersFile(path, "/"+filepath.Base(options.Input), csp); err != nil {
+			return result, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	if options.ServiceWorker != nil {
+		files := result.Files
+		if importMap != nil {
+			files = append(files, importMap.Files()...)
+		}
+		manifest, err := NewPrecacheManifest(files, outdir)
+		if err != nil {
+			return result, fmt.Errorf("failed to create precache manifest: %w", err)
+		}
+		if err := BuildServiceWorker(ctx, options.ServiceWorker, manifest, options.BuildOptions); err != nil {
+			return result, fmt.Errorf("failed to build service worker: %w", err)
+		}
+		result.Files = append(recorder.Outputs(), result.Output)
+	}
+
+	if options.Manifest {
+		path := filepath.Join(outdir, "manifest.json")
+		if manifest, err := NewManifest(recorder.Records(), recorder.DependencyRecords(), root, outdir); err != nil {
+			return result, fmt.Errorf("failed to create manifest: %w", err)
+		} else if err := manifest.WriteFile(path); err != nil {
+			return result, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	if options.Analyze {
+		path := filepath.Join(outdir, "report.html")
+		if analyses, err := NewBundleAnalysis(recorder.Records(), root, outdir); err != nil {
+			return result, fmt.Errorf("failed to analyze outputs: %w", err)
+		} else if err := writeFile(path, func(w io.Writer) error { return WriteBundleAnalysis(w, analyses) }); err != nil {
+			return result, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	if options.Metafile != "" {
+		metafiles := util.Map(recorder.Records(), func(r BuildRecord) string { return r.Result.Metafile })
+		if b, err := MergeMetafiles(metafiles); err != nil {
+			return result, fmt.Errorf("failed to merge metafiles: %w", err)
+		} else if err := os.WriteFile(options.Metafile, b, 0o644); err != nil {
+			return result, fmt.Errorf("failed to write %s: %w", options.Metafile, err)
+		}
+	}
+
+	return result, nil
+}
+
+// moduleScripts returns the references of the scripts that the document only loads as modules, which can import
+// chunks.
+func (d *Document) moduleScripts() map[string]bool {
+	modules := make(map[string]bool)
+	classic := make(map[string]bool)
+	var visit func(*html.Node)
+	visit = func(node *html.Node) {
+		if node.Type == html.ElementNode && node.DataAtom == atom.Script && getAttr(node, "src") != "" {
+			if strings.EqualFold(getAttr(node, "type"), "module") {
+				modules[getAttr(node, "src")] = true
+			} else {
+				classic[getAttr(node, "src")] = true
+			}
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(d.root)
+	for src := range classic {
+		delete(modules, src)
+	}
+	return modules
+}
+
+// parseDocumentFile parses the HTML document at path.
+func parseDocumentFile(path string, options ParseOptions) (*Document, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open entry point %s: %w", path, err)
+	}
+	defer file.Close()
+
+	doc, err := ParseDocument(file, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return doc, nil
+}
+
+// writeFile creates the file at path and writes to it using f.
+func writeFile(path string, f func(io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := f(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
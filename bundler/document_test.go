@@ -0,0 +1,187 @@
+package bundler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+func TestBuildDocument(t *testing.T) {
+	root := writeFiles(t, t.TempDir(), map[string]string{
+		"index.html": `<!doctype html><html><head><script type="module" src="/src/main.js"></script>` +
+			`<link rel="stylesheet" href="/src/style.css"></head><body><img src="/src/logo.png"></body></html>`,
+		"src/main.js":   "import 'lodash'; console.log('main');",
+		"src/style.css": "p { margin: 0 }",
+		"src/logo.png":  "png",
+	})
+	options := DocumentOptions{
+		BuildOptions: BuildOptions{
+			Mode:                BuildModeDevelopment,
+			OutputDirectory:     "dist",
+			ProjectRootAbsolute: root,
+			Loaders:             DefaultLoaders,
+		},
+		Input:     filepath.Join(root, "index.html"),
+		Externals: map[string]string{"lodash": "https://cdn/lodash.js"},
+		Inline:    InlineConfig{Images: 10},
+		Manifest:  true,
+	}
+
+	result, err := BuildDocument(context.Background(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outdir := filepath.Join(root, "dist")
+	if want := filepath.Join(outdir, "index.html"); result.Output != want {
+		t.Errorf("got output %s, want %s", result.Output, want)
+	}
+	b, err := os.ReadFile(result.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<!DOCTYPE html><html><head><script type="importmap">{"imports":{"lodash":"https://cdn/lodash.js"}}</script>` +
+		`<script type="module" src="./main.js"></script><link rel="stylesheet" href="./style.css"/></head>` +
+		`<body><img src="data:image/png;base64,cG5n"/></body></html>`
+	if got := string(b); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// the inlined image is removed
+	for _, name := range []string{"main.js", "style.css", "index.html", "manifest.json"} {
+		if _, err := os.Stat(filepath.Join(outdir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outdir, "logo.png")); err == nil {
+		t.Error("got logo.png, want it removed after inlining")
+	}
+	if len(result.Files) != 3 || len(result.Records) != 2 {
+		t.Errorf("got files %q and %d records, want 3 files and 2 records", result.Files, len(result.Records))
+	}
+
+	b, err = os.ReadFile(filepath.Join(outdir, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	keys := maps.Keys(manifest)
+	sort.Strings(keys)
+	if want := []string{"src/main.js", "src/style.css"}; !slices.Equal(keys, want) {
+		t.Errorf("got manifest entries %q, want %q", keys, want)
+	}
+	for source, entry := range manifest {
+		if _, err := os.Stat(filepath.Join(outdir, entry.File)); err != nil {
+			t.Errorf("%s: %v", source, err)
+		}
+	}
+}
+
+func TestBuildDocumentSplitting(t *testing.T) {
+	root := writeFiles(t, t.TempDir(), map[string]string{
+		"index.html": `<script type="module" src="/src/main.js"></script><script src="/src/classic.js"></script>`,
+		"src/main.js": "import { shared } from './shared.js'; shared(); " +
+			"import('./lazy.js').then(m => m.lazy());",
+		"src/classic.js": "import('./lazy.js').then(m => m.lazy());",
+		"src/lazy.js":    "import { shared } from './shared.js'; export function lazy() { shared(); }",
+		"src/shared.js":  "export function shared() { console.log('shared'); }",
+	})
+	options := DocumentOptions{
+		BuildOptions: BuildOptions{
+			Mode:                BuildModeDevelopment,
+			OutputDirectory:     "dist",
+			ProjectRootAbsolute: root,
+			Loaders:             DefaultLoaders,
+		},
+		Input:    filepath.Join(root, "index.html"),
+		Preload:  true,
+		Manifest: true,
+	}
+
+	result, err := BuildDocument(context.Background(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outdir := filepath.Join(root, "dist")
+	chunks, err := filepath.Glob(filepath.Join(outdir, "chunks", "chunk-*.js"))
+	if err != nil || len(chunks) != 1 {
+		t.Fatalf("got chunks %q, want one chunk shared by main.js and lazy.js", chunks)
+	}
+	chunk := "chunks/" + filepath.Base(chunks[0])
+	for _, record := range result.Records {
+		// the lazy chunk is an entry point in the metafile, too
+		if record.Output.Source == "src/main.js" && record.Output.Entry != filepath.Join(outdir, "main.js") {
+			t.Errorf("got entry %s for main.js", record.Output.Entry)
+		}
+	}
+
+	b, err := os.ReadFile(result.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<link rel="modulepreload" href="./` + chunk + `"/>`; !strings.Contains(string(b), want) {
+		t.Errorf("got\n%s\nwant it to contain %s", b, want)
+	}
+
+	b, err = os.ReadFile(filepath.Join(outdir, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if imports := manifest["src/main.js"].Imports; !slices.Contains(imports, chunk) {
+		t.Errorf("got imports %q of main.js, want %s among them", imports, chunk)
+	}
+	// the classic script cannot import chunks, so its dynamic import is bundled into it
+	if entry := manifest["src/classic.js"]; len(entry.Imports) != 0 || len(entry.Files) != 1 {
+		t.Errorf("got %+v for classic.js, want a single file without imports", entry)
+	}
+}
+
+func TestBuildDocumentOnWalk(t *testing.T) {
+	root := writeFiles(t, t.TempDir(), map[string]string{
+		"index.html": `<script src="/missing.js"></script>`,
+	})
+	options := DocumentOptions{
+		BuildOptions: BuildOptions{OutputDirectory: "dist", ProjectRootAbsolute: root, Loaders: DefaultLoaders},
+		Input:        filepath.Join(root, "index.html"),
+	}
+
+	var walkErr error
+	errStop := errors.New("stop")
+	options.OnWalk = func(err error) error {
+		walkErr = err
+		return errStop
+	}
+	if _, err := BuildDocument(context.Background(), options); err != errStop {
+		t.Errorf("got %v, want %v", err, errStop)
+	}
+	var depErr *DependencyError
+	if !errors.As(walkErr, &depErr) || depErr.Value != "/missing.js" {
+		t.Errorf("got %v, want a DependencyError for /missing.js", walkErr)
+	}
+	if _, err := os.Stat(filepath.Join(root, "dist", "index.html")); err == nil {
+		t.Error("got index.html, want nothing written")
+	}
+
+	options.OnWalk = func(err error) error { return nil }
+	if result, err := BuildDocument(context.Background(), options); err != nil {
+		t.Fatal(err)
+	} else if b, err := os.ReadFile(result.Output); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(string(b), "/missing.js") {
+		t.Errorf("got %s, want the failed reference kept", b)
+	}
+}
@@ -0,0 +1,282 @@
+package bundler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// A Document is a parsed HTML document whose references to scripts, stylesheets and images are built and rewritten.
+// Methods taking an output directory, outdir, look up the outputs the document references in it, as it is the
+// directory the document is served from.
+type Document struct {
+	name     string
+	root     *html.Node
+	targets  map[atom.Atom]string
+	source   *source
+	elements map[*html.Node]*elementSource
+	parsed   map[*html.Node]bool // the nodes created by the parser
+
+	// unlocated holds the elements created by the parser whose start tags could not be located in the source text.
+	unlocated map[*html.Node]*elementSource
+
+	// templates holds the template blocks replaced before parsing, if any.
+	templates *templates
+}
+
+// ParseOptions control how the input of a document is parsed.
+type ParseOptions struct {
+	// Fragment parses the input as the content of <body> instead of a complete document, which is written as is,
+	// without implied <html>, <head> and <body> elements.
+	Fragment bool
+
+	// Templates leaves template blocks like {{ ... }} and {% ... %} untouched. References containing them are not
+	// processed.
+	Templates bool
+}
+
+// A DependencyHandler processes the dependency referenced by ref and returns the reference to replace it by.
+type DependencyHandler func(ctx context.Context, ref string) (string, error)
+
+// A DependencyError reports the failure to process a dependency referenced by an element of a document.
+type DependencyError struct {
+	File  string // the name of the document, if known
+	Tag   string
+	Attr  string
+	Value string
+	Err   error
+
+	// Line (1-based) and Column (0-based, in bytes) locate the attribute value in the document, if known.
+	// Length is the length of the value and LineText the text of the line containing it.
+	Line, Column, Length int
+	LineText             string
+}
+
+func NewDocument(r io.Reader) (*Document, error) {
+	return ParseDocument(r, ParseOptions{})
+}
+
+// ParseDocument parses the document read from r according to options.
+func ParseDocument(r io.Reader, options ParseOptions) (*Document, error) {
+	return newDocument(r, map[atom.Atom]string{
+		atom.Script: "src",
+		atom.Link:   "href",
+		atom.Img:    "src",
+	}, options)
+}
+
+// NewDocumentWithOptions parses the document read from r with the dependencies given by targets, which maps tag
+// names to the attributes referencing them.
+func NewDocumentWithOptions(r io.Reader, targets map[string]string) (*Document, error) {
+	if refs, err := lookup(targets); err != nil {
+		return nil, err
+	} else {
+		return newDocument(r, refs, ParseOptions{})
+	}
+}
+
+// Walk calls h for every dependency of the document and replaces its reference by the returned path.
+// References to external resources, such as URLs with a scheme, are kept as they are.
+// Dependencies for which h fails are left as is; their errors are returned joined as DependencyErrors.
+// Once ctx is done, no further dependencies are processed and ctx.Err() is returned along with the other errors.
+func (d *Document) Walk(ctx context.Context, h DependencyHandler) error {
+	var errs []error
+	d.walk(ctx, d.root, h, &errs)
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// WriteTo renders the document to w and returns the number of bytes written, implementing io.WriterTo.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	return d.write(w, func(w io.Writer) error { return html.Render(w, d.root) })
+}
+
+// write writes the output of render to w, restoring template blocks if there are any.
+func (d *Document) write(w io.Writer, render func(io.Writer) error) (int64, error) {
+	cw := &countingWriter{w: w}
+	if d.templates == nil {
+		err := render(cw)
+		return cw.n, err
+	}
+	var b bytes.Buffer
+	if err := render(&b); err != nil {
+		return 0, err
+	}
+	_, err := cw.Write(d.templates.restore(b.Bytes()))
+	return cw.n, err
+}
+
+// newDocument parses the document read from r, which is named after the file if r is one.
+func newDocument(r io.Reader, targets map[atom.Atom]string, options ParseOptions) (*Document, error) {
+	text, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var tmpl *templates
+	if options.Templates {
+		tmpl, text = newTemplates(text)
+	}
+
+	var root *html.Node
+	if options.Fragment {
+		root = &html.Node{Type: html.DocumentNode}
+		// a <template> context keeps the table parts, list items and options a body context would drop
+		context := &html.Node{Type: html.ElementNode, Data: "template", DataAtom: atom.Template}
+		nodes, err := html.ParseFragment(bytes.NewReader(text), context)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			root.AppendChild(node)
+		}
+	} else if root, err = html.Parse(bytes.NewReader(text)); err != nil {
+		return nil, err
+	}
+
+	var name string
+	if f, ok := r.(interface{ Name() string }); ok {
+		name = f.Name()
+	}
+	src := newSource(text)
+	elements, unlocated := src.elements(root)
+	return &Document{name, root, targets, src, elements, descendants(root), unlocated, tmpl}, nil
+}
+
+func (d *Document) walk(ctx context.Context, node *html.Node, h DependencyHandler, errs *[]error) {
+	if ctx.Err() != nil {
+		return
+	}
+	if node.Type == html.ElementNode {
+		if name, ok := d.targets[node.DataAtom]; ok {
+			for i, attr := range node.Attr {
+				if attr.Key == name {
+					if isExternal(attr.Val) || d.templates != nil && d.templates.contains(attr.Val) {
+						break
+					}
+					if path, err := h(ctx, attr.Val); err != nil {
+						if ctx.Err() == nil || !errors.Is(err, ctx.Err()) {
+							*errs = append(*errs, d.dependencyError(node, attr, err))
+						}
+					} else {
+						node.Attr[i].Val = path
+					}
+					break
+				}
+			}
+		}
+	}
+
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		d.walk(ctx, c, h, errs)
+	}
+}
+
+func (d *Document) dependencyError(node *html.Node, attr html.Attribute, err error) *DependencyError {
+	e := &DependencyError{File: d.name, Tag: node.Data, Attr: attr.Key, Value: attr.Val, Err: err}
+	if pos, length, ok := d.attrLocation(node, attr.Key); ok {
+		e.Line, e.Column, e.Length = pos.Line, pos.Column, length
+		e.LineText = d.restoreText(d.source.lineText(pos.Line))
+	}
+	return e
+}
+
+func (e *DependencyError) Error() string {
+	element := fmt.Sprintf("<%s %s=%q>", e.Tag, e.Attr, e.Value)
+	if e.Line > 0 {
+		element = fmt.Sprintf("[Ln %d, Col %d]: %s", e.Line, e.Column, element)
+	}
+	if e.File != "" {
+		element = e.File + " " + element
+	}
+	return fmt.Sprintf("%s: %s", element, e.Err)
+}
+
+func (e *DependencyError) Unwrap() error {
+	return e.Err
+}
+
+func lookup(m map[string]string) (map[atom.Atom]string, error) {
+	r := make(map[atom.Atom]string, len(m))
+	for tag, attr := range m {
+		if t := atom.Lookup([]byte(tag)); t == 0 {
+			return nil, fmt.Errorf("unknown tag %q", tag)
+		} else {
+			r[t] = attr
+		}
+	}
+	return r, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// isExternal reports whether the reference s does not refer to a file of the project.
+func isExternal(s string) bool {
+	if strings.HasPrefix(s, "//") {
+		return true
+	}
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != ""
+}
+
+// descendants returns the set of nodes below root.
+func descendants(root *html.Node) map[*html.Node]bool {
+	nodes := make(map[*html.Node]bool)
+	var visit func(*html.Node)
+	visit = func(node *html.Node) {
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			nodes[c] = true
+			visit(c)
+		}
+	}
+	visit(root)
+	return nodes
+}
+
+// head returns the <head> element of the document or, for a fragment, its root.
+func (d *Document) head() *html.Node {
+	if head := findElement(d.root, func(n *html.Node) bool { return n.DataAtom == atom.Head }); head != nil {
+		return head
+	}
+	return d.root
+}
+
+// findElement returns the first element below node in document order for which f evaluates to true.
+func findElement(node *html.Node, f func(*html.Node) bool) *html.Node {
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && f(c) {
+			return c
+		}
+		if n := findElement(c, f); n != nil {
+			return n
+		}
+	}
+	return nil
+}
+
+// getAttr returns the value of the node's attribute with the given key or the empty string if there is none.
+func getAttr(node *html.Node, key string) string {
+	for _, attr := range node.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
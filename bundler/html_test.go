@@ -0,0 +1,367 @@
+package bundler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+func TestDocumentAttrLocation(t *testing.T) {
+	src := "<!DOCTYPE html>\n<html><head>\n" +
+		"<noscript><img src=\"/a.png\"></noscript>\n" +
+		"<SCRIPT type=module SRC = '/src/main.js'></SCRIPT>\n" +
+		"</head><body>\n\t<img alt=x src=/b.png>\n</body></html>\n"
+
+	doc, err := NewDocument(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcs := []struct {
+		tag    atom.Atom
+		attr   string
+		want   Position
+		length int
+	}{
+		{atom.Script, "src", Position{96, 4, 27}, 12},
+		{atom.Img, "src", Position{150, 6, 16}, 6},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.tag.String(), func(t *testing.T) {
+			node := findElement(doc.root, func(n *html.Node) bool { return n.DataAtom == tc.tag })
+			pos, length, ok := doc.attrLocation(node, tc.attr)
+			if !ok {
+				t.Fatal("got no location")
+			}
+			if pos != tc.want || length != tc.length {
+				t.Errorf("got %+v with length %d, want %+v with length %d", pos, length, tc.want, tc.length)
+			}
+			if got := src[pos.Offset : pos.Offset+length]; got != getAttr(node, tc.attr) {
+				t.Errorf("got %q, want %q", got, getAttr(node, tc.attr))
+			}
+		})
+	}
+}
+
+func TestDocumentWriteSourceTo(t *testing.T) {
+	tcs := []struct {
+		name string
+		src  string
+		edit func(doc *Document)
+		want string
+	}{
+		{
+			"document",
+			"<!doctype html>\n<HTML>\n<head>\n  <title>{{ .Title }}</title>\n" +
+				"  <link rel=stylesheet href=style.css>\n  <script type=\"module\" src='main.js' async></script>\n" +
+				"</head>\n<body>\n  <img src=a.png alt=\"\"/>\n  <p>{{ range .Items }}<br>{{ end }}</p>\n</body>\n</HTML>\n",
+			func(doc *Document) {
+				img := findElement(doc.root, func(n *html.Node) bool { return n.DataAtom == atom.Img })
+				img.Parent.RemoveChild(img)
+				head := findElement(doc.root, func(n *html.Node) bool { return n.DataAtom == atom.Head })
+				script := findElement(head, func(n *html.Node) bool { return n.DataAtom == atom.Script })
+				script.Attr = append(script.Attr[:2], html.Attribute{Key: "defer"})
+				head.AppendChild(&html.Node{Type: html.ElementNode, Data: "meta", DataAtom: atom.Meta,
+					Attr: []html.Attribute{{Key: "charset", Val: "utf-8"}}})
+			},
+			"<!doctype html>\n<HTML>\n<head>\n  <title>{{ .Title }}</title>\n" +
+				"  <link rel=stylesheet href=\"./style.css?v=1&amp;x\">\n  <script type=\"module\" src='./main.js?v=1&amp;x' defer=\"\"></script>\n" +
+				"<meta charset=\"utf-8\"/></head>\n<body>\n  \n  <p>{{ range .Items }}<br>{{ end }}</p>\n</body>\n</HTML>\n",
+		},
+		{
+			// the parser moves the second image ahead of the table
+			"misplaced in table",
+			"<table><tr><td><img src=\"a.png\"></td></tr><img src=\"b.png\"></table>",
+			func(*Document) {},
+			"<table><tr><td><img src=\"./a.png?v=1&amp;x\"></td></tr><img src=\"./b.png?v=1&amp;x\"></table>",
+		},
+		{
+			// the parser turns <image> into <img>, so the document is rendered instead
+			"renamed by parser",
+			"<p><image src=\"a.png\"></p>\n",
+			func(*Document) {},
+			"<html><head></head><body><p><img src=\"./a.png?v=1&amp;x\"/></p>\n</body></html>",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := NewDocument(strings.NewReader(tc.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc.Walk(context.Background(), func(_ context.Context, path string) (string, error) {
+				return "./" + path + "?v=1&x", nil
+			})
+			tc.edit(doc)
+
+			var b strings.Builder
+			if _, err := doc.WriteSourceTo(&b); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tc.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDocumentWriteMinifiedTo(t *testing.T) {
+	tcs := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			"document",
+			"<!DOCTYPE html>\n<html>\n<head>\n  <!-- drop me -->\n  <!--! keep me -->\n" +
+				"  <script type=\"text/javascript\" src=\"a.js?x=1&amp;y=2\"></script>\n" +
+				"  <style type=text/css> p { margin: 0 } </style>\n</head>\n<body>\n  <p class=\"a b\">\n" +
+				"    Hello,   <b>world</b> !\n  </p>\n  <pre>\n  keep\n   this</pre>\n" +
+				"  <input type=text value=\"\" disabled>\n</body>\n</html>\n",
+			"<!doctype html><html><head><!--! keep me --><script src=\"a.js?x=1&amp;y=2\"></script>" +
+				"<style> p { margin: 0 } </style></head><body><p class=\"a b\">Hello, <b>world</b> !</p>" +
+				"<pre>  keep\n   this</pre><input value disabled></body></html>",
+		},
+		{
+			"custom element",
+			"<p>Click <my-link>x</my-link> now</p>",
+			"<html><head></head><body><p>Click <my-link>x</my-link> now</p></body></html>",
+		},
+		{
+			"script and template",
+			"<div>\n  <p>a</p>\n  b <script>c()</script> d <template>e</template> f\n</div>",
+			"<html><head></head><body><div><p>a</p>b <script>c()</script> d <template>e</template> f</div></body></html>",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := NewDocument(strings.NewReader(tc.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b strings.Builder
+			if _, err := doc.WriteMinifiedTo(&b); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tc.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseDocumentTemplates(t *testing.T) {
+	src := "{% block head %}\n<script src=\"main.js\" {{ if .Async }}async{{ end }}></script>\n" +
+		"<img src=\"{{ .CDN }}/logo.png\" alt='{{ .Alt }}'>\n{% endblock %}\n"
+
+	doc, err := ParseDocument(strings.NewReader(src), ParseOptions{Fragment: true, Templates: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	doc.Walk(context.Background(), func(_ context.Context, path string) (string, error) {
+		paths = append(paths, path)
+		return "./" + path, nil
+	})
+	if len(paths) != 1 || paths[0] != "main.js" {
+		t.Errorf("got dependencies %q, want only main.js", paths)
+	}
+
+	var b strings.Builder
+	if _, err := doc.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := "{% block head %}\n<script src=\"./main.js\" {{ if .Async }}async{{ end }}></script>\n" +
+		"<img src=\"{{ .CDN }}/logo.png\" alt=\"{{ .Alt }}\"/>\n{% endblock %}\n"
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParseDocumentFragment(t *testing.T) {
+	tcs := []struct {
+		name string
+		src  string
+	}{
+		{"table row", "<tr><td>{{ .X }}</td></tr>"},
+		{"table cell", "<td><img src=\"a.png\"></td>"},
+		{"list item", "<li>{{ .X }}</li>\n<li><a href=\"b.html\">b</a></li>"},
+		{"option", "<option value=\"{{ .X }}\">{{ .Y }}</option>"},
+		{"paragraph", "<p>a</p>"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := ParseDocument(strings.NewReader(tc.src), ParseOptions{Fragment: true, Templates: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b strings.Builder
+			if _, err := doc.WriteSourceTo(&b); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tc.src {
+				t.Errorf("source: got %q, want %q", got, tc.src)
+			}
+			b.Reset()
+			if _, err := doc.WriteTo(&b); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); strings.ReplaceAll(got, "/>", ">") != tc.src {
+				t.Errorf("rendered: got %q, want %q", got, tc.src)
+			}
+		})
+	}
+}
+
+func TestDocumentWalkErrors(t *testing.T) {
+	errFailed := errors.New("failed")
+	handler := func(_ context.Context, path string) (string, error) {
+		if strings.HasPrefix(path, "bad") {
+			return "", errFailed
+		}
+		return "./" + path, nil
+	}
+
+	tcs := []struct {
+		name string
+		src  string
+		want []DependencyError // Err is always errFailed
+		out  string
+	}{
+		{
+			"none",
+			"<script src=a.js></script>",
+			nil,
+			"<script src=./a.js></script>",
+		},
+		{
+			"several",
+			"<script src=bad1.js></script>\n<img src=a.png>\n  <link rel=stylesheet href='bad2.css'>",
+			[]DependencyError{
+				{Tag: "script", Attr: "src", Value: "bad1.js", Line: 1, Column: 12, Length: 7,
+					LineText: "<script src=bad1.js></script>"},
+				{Tag: "link", Attr: "href", Value: "bad2.css", Line: 3, Column: 29, Length: 8,
+					LineText: "  <link rel=stylesheet href='bad2.css'>"},
+			},
+			"<script src=bad1.js></script>\n<img src=./a.png>\n  <link rel=stylesheet href='bad2.css'>",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := ParseDocument(strings.NewReader(tc.src), ParseOptions{Fragment: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = doc.Walk(context.Background(), handler)
+
+			var errs []error
+			if err != nil {
+				errs = err.(interface{ Unwrap() []error }).Unwrap()
+			}
+			if len(errs) != len(tc.want) {
+				t.Fatalf("got %d errors, want %d: %v", len(errs), len(tc.want), err)
+			}
+			for i, err := range errs {
+				var got *DependencyError
+				if !errors.As(err, &got) {
+					t.Fatalf("got %v, want a *DependencyError", err)
+				}
+				if !errors.Is(got, errFailed) {
+					t.Errorf("got %v, want it to wrap %v", got.Err, errFailed)
+				}
+				want := tc.want[i]
+				want.Err = got.Err
+				if *got != want {
+					t.Errorf("got %+v, want %+v", *got, want)
+				}
+			}
+
+			var b strings.Builder
+			if _, err := doc.WriteSourceTo(&b); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tc.out {
+				t.Errorf("got\n%s\nwant\n%s", got, tc.out)
+			}
+		})
+	}
+}
+
+func TestDocumentWalkCanceled(t *testing.T) {
+	src := `<script src="a.js"></script><script src="b.js"></script><script src="c.js"></script>`
+
+	tcs := []struct {
+		name     string
+		cancelAt string // the dependency whose handler cancels, or empty to cancel before walking
+		err      error  // returned by the handler that cancels
+		calls    []string
+		out      string
+	}{
+		{"before", "", nil, nil, src},
+		{
+			"during",
+			"b.js",
+			nil,
+			[]string{"a.js", "b.js"},
+			`<script src="./a.js"></script><script src="./b.js"></script><script src="c.js"></script>`,
+		},
+		{
+			"failing handler",
+			"b.js",
+			context.Canceled,
+			[]string{"a.js", "b.js"},
+			`<script src="./a.js"></script><script src="b.js"></script><script src="c.js"></script>`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := ParseDocument(strings.NewReader(src), ParseOptions{Fragment: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancelAt == "" {
+				cancel()
+			}
+
+			var calls []string
+			err = doc.Walk(ctx, func(ctx context.Context, path string) (string, error) {
+				calls = append(calls, path)
+				if path == tc.cancelAt {
+					cancel()
+					if tc.err != nil {
+						return "", tc.err
+					}
+				}
+				return "./" + path, nil
+			})
+			if errs, ok := err.(interface{ Unwrap() []error }); !ok || len(errs.Unwrap()) != 1 ||
+				errs.Unwrap()[0] != context.Canceled {
+				t.Errorf("got %v, want only %v", err, context.Canceled)
+			}
+			if !slices.Equal(calls, tc.calls) {
+				t.Errorf("got calls %q, want %q", calls, tc.calls)
+			}
+
+			var b strings.Builder
+			if _, err := doc.WriteSourceTo(&b); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tc.out {
+				t.Errorf("got\n%s\nwant\n%s", got, tc.out)
+			}
+		})
+	}
+}
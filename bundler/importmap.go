@@ -0,0 +1,140 @@
+package bundler
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// An ImportMap is the content of a <script type="importmap"> element.
+type ImportMap struct {
+	Imports map[string]string            `json:"imports,omitempty"`
+	Scopes  map[string]map[string]string `json:"scopes,omitempty"`
+
+	files []string // the vendor files copied by NewImportMap
+}
+
+// NewImportMap returns an import map for the given externals, which map bare import specifiers to either a URL or
+// a file relative to root. Files are copied into the vendor subdirectory of outdir and mapped relative to it.
+func NewImportMap(externals map[string]string, root, outdir string) (*ImportMap, error) {
+	m := &ImportMap{Imports: make(map[string]string, len(externals))}
+	for specifier, target := range externals {
+		if isURL(target) {
+			m.Imports[specifier] = target
+			continue
+		}
+		name := path.Join("vendor", specifier+filepath.Ext(target))
+		dst := filepath.Join(outdir, filepath.FromSlash(name))
+		if err := copyFile(filepath.Join(root, target), dst); err != nil {
+			return nil, err
+		}
+		m.files = append(m.files, dst)
+		m.Imports[specifier] = "./" + name
+	}
+	return m, nil
+}
+
+// Files returns the paths of the vendor files copied into the output directory.
+func (m *ImportMap) Files() []string {
+	return m.files
+}
+
+// Merge adds the entries of n that are not yet present in m.
+func (m *ImportMap) Merge(n *ImportMap) {
+	m.Imports = mergeSpecifierMap(m.Imports, n.Imports)
+	for scope, imports := range n.Scopes {
+		if m.Scopes == nil {
+			m.Scopes = make(map[string]map[string]string, len(n.Scopes))
+		}
+		m.Scopes[scope] = mergeSpecifierMap(m.Scopes[scope], imports)
+	}
+}
+
+// MergeImportMap merges m into the document's import map. If the document has none, a <script type="importmap">
+// is inserted into <head>, or at the top level of a fragment, ahead of any scripts and links, as import maps must
+// precede the modules they apply to.
+func (d *Document) MergeImportMap(m *ImportMap) error {
+	node := findElement(d.root, func(n *html.Node) bool {
+		return n.DataAtom == atom.Script && getAttr(n, "type") == "importmap"
+	})
+
+	if node == nil {
+		node = &html.Node{
+			Type:     html.ElementNode,
+			Data:     "script",
+			DataAtom: atom.Script,
+			Attr:     []html.Attribute{{Key: "type", Val: "importmap"}},
+		}
+		head := d.head()
+		next := findElement(head, func(n *html.Node) bool {
+			return n.Parent == head && (n.DataAtom == atom.Script || n.DataAtom == atom.Link)
+		})
+		head.InsertBefore(node, next)
+	}
+
+	existing := &ImportMap{}
+	if text := node.FirstChild; text != nil && strings.TrimSpace(text.Data) != "" {
+		if err := json.Unmarshal([]byte(text.Data), existing); err != nil {
+			return err
+		}
+	}
+	existing.Merge(m)
+
+	b, err := json.Marshal(existing)
+	if err != nil {
+		return err
+	}
+	for node.FirstChild != nil {
+		node.RemoveChild(node.FirstChild)
+	}
+	node.AppendChild(&html.Node{Type: html.TextNode, Data: string(b)})
+	return nil
+}
+
+func mergeSpecifierMap(m, n map[string]string) map[string]string {
+	if m == nil && len(n) > 0 {
+		m = make(map[string]string, len(n))
+	}
+	for specifier, target := range n {
+		if _, ok := m[specifier]; !ok {
+			m[specifier] = target
+		}
+	}
+	return m
+}
+
+// isURL reports whether s is an absolute or protocol-relative URL.
+func isURL(s string) bool {
+	if strings.HasPrefix(s, "//") {
+		return true
+	}
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
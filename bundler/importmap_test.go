@@ -0,0 +1,80 @@
+package bundler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDocumentMergeImportMap(t *testing.T) {
+	m := &ImportMap{
+		Imports: map[string]string{"a": "https://cdn.example.com/a.js", "b": "./vendor/b.js"},
+		Scopes:  map[string]map[string]string{"/x/": {"c": "./vendor/c.js"}},
+	}
+
+	tcs := []struct {
+		name     string
+		src      string
+		fragment bool
+		want     string
+	}{
+		{
+			"no import map",
+			`<html><head><title>t</title><link rel=stylesheet href=s.css><script src=m.js></script></head></html>`,
+			false,
+			`<html><head><title>t</title><script type="importmap">{"imports":{"a":"https://cdn.example.com/a.js",` +
+				`"b":"./vendor/b.js"},"scopes":{"/x/":{"c":"./vendor/c.js"}}}</script><link rel="stylesheet" href="s.css"/>` +
+				`<script src="m.js"></script></head><body></body></html>`,
+		},
+		{
+			"existing import map",
+			`<html><head><script type=importmap>{"imports":{"a":"./a.js"},"scopes":{"/x/":{"d":"./d.js"}}}</script>` +
+				`</head></html>`,
+			false,
+			`<html><head><script type="importmap">{"imports":{"a":"./a.js","b":"./vendor/b.js"},` +
+				`"scopes":{"/x/":{"c":"./vendor/c.js","d":"./d.js"}}}</script></head><body></body></html>`,
+		},
+		{
+			"empty import map",
+			`<html><head><script type=importmap> </script></head></html>`,
+			false,
+			`<html><head><script type="importmap">{"imports":{"a":"https://cdn.example.com/a.js",` +
+				`"b":"./vendor/b.js"},"scopes":{"/x/":{"c":"./vendor/c.js"}}}</script></head><body></body></html>`,
+		},
+		{
+			"fragment",
+			`<p>p</p><script type=module src=m.js></script>`,
+			true,
+			`<p>p</p><script type="importmap">{"imports":{"a":"https://cdn.example.com/a.js",` +
+				`"b":"./vendor/b.js"},"scopes":{"/x/":{"c":"./vendor/c.js"}}}</script><script type="module" src="m.js"></script>`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := ParseDocument(strings.NewReader(tc.src), ParseOptions{Fragment: tc.fragment})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := doc.MergeImportMap(m); err != nil {
+				t.Fatal(err)
+			}
+			var b strings.Builder
+			if _, err := doc.WriteTo(&b); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tc.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("invalid import map", func(t *testing.T) {
+		doc, err := NewDocument(strings.NewReader(`<script type=importmap>{</script>`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := doc.MergeImportMap(m); err == nil {
+			t.Error("got no error")
+		}
+	})
+}
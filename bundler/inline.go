@@ -0,0 +1,156 @@
+package bundler
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/fs"
+	"mime"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// closingTag matches the end tags that would terminate inlined script or style content prematurely.
+var closingTag = regexp.MustCompile(`(?i)</(script|style)`)
+
+// Inline replaces the references of scripts, stylesheets and images whose URLs resolve to files in dir, the
+// directory the document is served from, by the content of these files if they are smaller than the limits in
+// config. Scripts and stylesheets become inline <script> and <style> elements, images data: URLs. Classic scripts
+// with async or defer attributes are not inlined, as these have no effect on inline scripts.
+// Inline returns the paths of the inlined files.
+func (d *Document) Inline(dir string, config InlineConfig) ([]string, error) {
+	var nodes []*html.Node
+	var visit func(*html.Node)
+	visit = func(node *html.Node) {
+		if node.Type == html.ElementNode && node.Namespace == "" {
+			nodes = append(nodes, node)
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(d.root)
+
+	var paths []string
+	var errs []error
+	seen := make(map[string]bool)
+	for _, node := range nodes {
+		var key string
+		var limit Size
+		switch {
+		case node.DataAtom == atom.Script && inlinableScript(node):
+			key, limit = "src", config.Scripts
+		case node.DataAtom == atom.Link && hasRel(node, "stylesheet"):
+			key, limit = "href", config.Styles
+		case node.DataAtom == atom.Img:
+			key, limit = "src", config.Images
+		default:
+			continue
+		}
+		if limit <= 0 {
+			continue
+		}
+
+		attr := html.Attribute{Key: key, Val: getAttr(node, key)}
+		path, b, err := readInlinable(dir, attr.Val, limit)
+		if err != nil {
+			errs = append(errs, d.dependencyError(node, attr, err))
+			continue
+		} else if b == nil {
+			continue
+		}
+
+		switch node.DataAtom {
+		case atom.Script:
+			node.Attr = removeAttr(node.Attr, "src")
+			node.AppendChild(&html.Node{Type: html.TextNode, Data: escapeClosingTags(string(b))})
+		case atom.Link:
+			style := &html.Node{Type: html.ElementNode, Data: "style", DataAtom: atom.Style}
+			if media := getAttr(node, "media"); media != "" {
+				style.Attr = []html.Attribute{{Key: "media", Val: media}}
+			}
+			style.AppendChild(&html.Node{Type: html.TextNode, Data: escapeClosingTags(string(b))})
+			node.Parent.InsertBefore(style, node)
+			node.Parent.RemoveChild(node)
+		case atom.Img:
+			typ := mime.TypeByExtension(filepath.Ext(path))
+			if typ == "" {
+				typ = "application/octet-stream"
+			}
+			for i := range node.Attr {
+				if node.Attr[i].Key == "src" {
+					node.Attr[i].Val = "data:" + typ + ";base64," + base64.StdEncoding.EncodeToString(b)
+				}
+			}
+		}
+
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	return paths, errors.Join(errs...)
+}
+
+// readInlinable reads the file in dir referenced by ref if it is smaller than limit. It returns nil if ref does not
+// refer to a file in dir or the file is too large.
+func readInlinable(dir, ref string, limit Size) (string, []byte, error) {
+	path, ok := outputFile(dir, ref)
+	if !ok {
+		return "", nil, nil
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil, nil
+	} else if err != nil {
+		return "", nil, err
+	} else if info.IsDir() || info.Size() >= int64(limit) {
+		return "", nil, nil
+	}
+	b, err := os.ReadFile(path)
+	return path, b, err
+}
+
+func inlinableScript(node *html.Node) bool {
+	if getAttr(node, "src") == "" {
+		return false
+	}
+	if typ := strings.ToLower(getAttr(node, "type")); typ == "module" {
+		return true
+	} else if !isExecutableScript(typ) || typ == "importmap" {
+		return false
+	}
+	_, async := findAttr(node.Attr, "async")
+	_, deferred := findAttr(node.Attr, "defer")
+	return !async && !deferred
+}
+
+// hasRel reports whether the rel attribute of node contains the link type.
+func hasRel(node *html.Node, typ string) bool {
+	for _, rel := range strings.Fields(getAttr(node, "rel")) {
+		if strings.EqualFold(rel, typ) {
+			return true
+		}
+	}
+	return false
+}
+
+func removeAttr(attrs []html.Attribute, key string) []html.Attribute {
+	result := attrs[:0]
+	for _, attr := range attrs {
+		if attr.Key != key {
+			result = append(result, attr)
+		}
+	}
+	return result
+}
+
+// escapeClosingTags escapes </script and </style in inlined content, which is valid in both JavaScript strings and
+// regular expressions and in CSS.
+func escapeClosingTags(s string) string {
+	return closingTag.ReplaceAllString(s, `<\/$1`)
+}
@@ -0,0 +1,95 @@
+package bundler
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDocumentInline(t *testing.T) {
+	dir := writeFiles(t, t.TempDir(), map[string]string{
+		"small.js":  "a()",
+		"large.js":  "abcdefghij",
+		"close.js":  "s='</script>'",
+		"small.css": "p{}",
+		"large.css": "p{margin:0}",
+		"a.png":     "png",
+		"b.svg":     "<svg/>",
+	})
+	config := InlineConfig{Scripts: 10, Styles: 10, Images: 10}
+
+	tcs := []struct {
+		name   string
+		src    string
+		config InlineConfig
+		want   string
+		paths  []string
+	}{
+		{
+			"below limits",
+			`<script src="./small.js"></script><link rel="stylesheet" href="small.css" media="print"/><img src="a.png"/>`,
+			config,
+			`<script>a()</script><style media="print">p{}</style><img src="data:image/png;base64,cG5n"/>`,
+			[]string{"small.js", "small.css", "a.png"},
+		},
+		{
+			"at or above limits",
+			`<script src="large.js"></script><link rel="stylesheet" href="large.css"/>`,
+			config,
+			`<script src="large.js"></script><link rel="stylesheet" href="large.css"/>`,
+			nil,
+		},
+		{
+			"disabled",
+			`<script src="small.js"></script><link rel="stylesheet" href="small.css"/><img src="a.png"/>`,
+			InlineConfig{Styles: 10},
+			`<script src="small.js"></script><style>p{}</style><img src="a.png"/>`,
+			[]string{"small.css"},
+		},
+		{
+			"scripts",
+			`<script type="module" src="small.js" async></script><script src="small.js" defer></script>` +
+				`<script type="application/ld+json" src="small.js"></script><script src="close.js"></script>`,
+			InlineConfig{Scripts: 100},
+			`<script type="module" async="">a()</script><script src="small.js" defer=""></script>` +
+				`<script type="application/ld+json" src="small.js"></script><script>s='<\/script>'</script>`,
+			[]string{"small.js", "close.js"},
+		},
+		{
+			"repeated and unknown",
+			`<img src="b.svg"/><img src="./b.svg"/><img src="missing.png"/><img src="https://cdn/a.png"/>`,
+			config,
+			`<img src="data:image/svg+xml;base64,PHN2Zy8+"/><img src="data:image/svg+xml;base64,PHN2Zy8+"/>` +
+				`<img src="missing.png"/><img src="https://cdn/a.png"/>`,
+			[]string{"b.svg"},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := ParseDocument(strings.NewReader(tc.src), ParseOptions{Fragment: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			paths, err := doc.Inline(dir, tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b strings.Builder
+			if _, err := doc.WriteTo(&b); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tc.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tc.want)
+			}
+			if len(paths) != len(tc.paths) {
+				t.Fatalf("got inlined files %q, want %q", paths, tc.paths)
+			}
+			for i, path := range paths {
+				if want := filepath.Join(dir, tc.paths[i]); path != want {
+					t.Errorf("got inlined file %s, want %s", path, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package bundler
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDocumentAddIntegrity(t *testing.T) {
+	dir := writeFiles(t, t.TempDir(), map[string]string{
+		"main.js":      "console.log('main');",
+		"style.css":    "p { margin: 0 }",
+		"chunks/a.js":  "export const a = 1;",
+		"font.woff2":   "wOF2",
+		"images/a.png": "png",
+		"empty.css":    "",
+	})
+	digest := func(name string) string {
+		b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha512.Sum384(b)
+		return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+	}
+
+	tcs := []struct {
+		name        string
+		src         string
+		crossOrigin string
+		want        string
+	}{
+		{
+			"script",
+			`<script type="module" src="./main.js?v=1"></script>`,
+			"anonymous",
+			`<script type="module" src="./main.js?v=1" integrity="` + digest("main.js") + `" crossorigin="anonymous"></script>`,
+		},
+		{
+			"stylesheet without crossorigin",
+			`<link rel="stylesheet" href="style.css"/>`,
+			"",
+			`<link rel="stylesheet" href="style.css" integrity="` + digest("style.css") + `"/>`,
+		},
+		{
+			"preloads",
+			`<link rel="modulepreload" href="./chunks/a.js"/><link rel="preload" href="font.woff2" as="font" crossorigin/>`,
+			"use-credentials",
+			`<link rel="modulepreload" href="./chunks/a.js" integrity="` + digest("chunks/a.js") +
+				`" crossorigin="use-credentials"/><link rel="preload" href="font.woff2" as="font" crossorigin="" integrity="` +
+				digest("font.woff2") + `"/>`,
+		},
+		{
+			"empty file",
+			`<link rel="stylesheet" href="empty.css"/>`,
+			"",
+			`<link rel="stylesheet" href="empty.css" integrity="` + digest("empty.css") + `"/>`,
+		},
+		{
+			"left as is",
+			`<script src="main.js" integrity="sha384-x"></script><script src="https://cdn/a.js"></script>` +
+				`<script src="./missing.js"></script><script>a()</script><link rel="icon" href="images/a.png"/>` +
+				`<img src="images/a.png"/>`,
+			"anonymous",
+			`<script src="main.js" integrity="sha384-x"></script><script src="https://cdn/a.js"></script>` +
+				`<script src="./missing.js"></script><script>a()</script><link rel="icon" href="images/a.png"/>` +
+				`<img src="images/a.png"/>`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := ParseDocument(strings.NewReader(tc.src), ParseOptions{Fragment: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := doc.AddIntegrity(dir, tc.crossOrigin); err != nil {
+				t.Fatal(err)
+			}
+			var b strings.Builder
+			if _, err := doc.WriteTo(&b); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tc.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
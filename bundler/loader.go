@@ -0,0 +1,84 @@
+package bundler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+var loaderNames = map[string]api.Loader{
+	"base64":  api.LoaderBase64,
+	"binary":  api.LoaderBinary,
+	"copy":    api.LoaderCopy,
+	"css":     api.LoaderCSS,
+	"dataurl": api.LoaderDataURL,
+	"empty":   api.LoaderEmpty,
+	"file":    api.LoaderFile,
+	"js":      api.LoaderJS,
+	"json":    api.LoaderJSON,
+	"jsx":     api.LoaderJSX,
+	"text":    api.LoaderText,
+	"ts":      api.LoaderTS,
+	"tsx":     api.LoaderTSX,
+}
+
+// DefaultLoaders maps the file extensions cvbuild handles out of the box to their loaders.
+var DefaultLoaders = map[string]api.Loader{
+	".js":  api.LoaderJS,
+	".mjs": api.LoaderJS,
+	".cjs": api.LoaderJS,
+	".jsx": api.LoaderJSX,
+	".ts":  api.LoaderTS,
+	".mts": api.LoaderTS,
+	".cts": api.LoaderTS,
+	".tsx": api.LoaderTSX,
+
+	".css":  api.LoaderCSS,
+	".json": api.LoaderJSON,
+	".txt":  api.LoaderText,
+
+	".avif":  api.LoaderFile,
+	".gif":   api.LoaderFile,
+	".ico":   api.LoaderFile,
+	".jpeg":  api.LoaderFile,
+	".jpg":   api.LoaderFile,
+	".png":   api.LoaderFile,
+	".svg":   api.LoaderFile,
+	".webp":  api.LoaderFile,
+	".eot":   api.LoaderFile,
+	".otf":   api.LoaderFile,
+	".ttf":   api.LoaderFile,
+	".woff":  api.LoaderFile,
+	".woff2": api.LoaderFile,
+	".wasm":  api.LoaderFile,
+}
+
+// NewLoaders returns DefaultLoaders overridden by the given mapping of file extensions to loader names.
+func NewLoaders(loaders map[string]string) (map[string]api.Loader, error) {
+	r := make(map[string]api.Loader, len(DefaultLoaders)+len(loaders))
+	for ext, loader := range DefaultLoaders {
+		r[ext] = loader
+	}
+	for ext, name := range loaders {
+		if !strings.HasPrefix(ext, ".") {
+			return nil, fmt.Errorf("invalid file extension %q", ext)
+		}
+		if loader, ok := loaderNames[name]; !ok {
+			return nil, fmt.Errorf("unknown loader %q for %s files", name, ext)
+		} else {
+			r[ext] = loader
+		}
+	}
+	return r, nil
+}
+
+// isScriptLoader reports whether the loader parses its input as JavaScript or one of its dialects.
+func isScriptLoader(loader api.Loader) bool {
+	switch loader {
+	case api.LoaderJS, api.LoaderJSX, api.LoaderTS, api.LoaderTSX:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,45 @@
+package bundler
+
+import (
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestNewLoaders(t *testing.T) {
+	tcs := []struct {
+		name    string
+		loaders map[string]string
+		want    map[string]api.Loader // the loaders expected for some extensions
+		wantErr bool
+	}{
+		{"defaults", nil, map[string]api.Loader{".ts": api.LoaderTS, ".svg": api.LoaderFile}, false},
+		{"override", map[string]string{".svg": "dataurl"}, map[string]api.Loader{".svg": api.LoaderDataURL, ".png": api.LoaderFile}, false},
+		{"new extension", map[string]string{".glsl": "text"}, map[string]api.Loader{".glsl": api.LoaderText}, false},
+		{"invalid extension", map[string]string{"svg": "dataurl"}, nil, true},
+		{"unknown loader", map[string]string{".svg": "image"}, nil, true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NewLoaders(tc.loaders)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("got no error")
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			for ext, loader := range tc.want {
+				if got[ext] != loader {
+					t.Errorf("got loader %d for %s, want %d", got[ext], ext, loader)
+				}
+			}
+		})
+	}
+
+	if DefaultLoaders[".svg"] != api.LoaderFile {
+		t.Error("got DefaultLoaders modified")
+	}
+}
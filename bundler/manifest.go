@@ -0,0 +1,105 @@
+package bundler
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// A Manifest maps the source paths referenced by a document, and the workers referenced relative to import.meta.url
+// by its scripts, to the files emitted for them. Source paths are relative to the project root, with forward slashes.
+type Manifest map[string]ManifestEntry
+
+type ManifestEntry struct {
+	// File is the output that corresponds to the entry point, relative to the output directory.
+	File string `json:"file"`
+
+	// Imports lists the chunks imported by File.
+	Imports []string `json:"imports,omitempty"`
+
+	// Files lists all outputs of the entry point, including File.
+	Files []ManifestFile `json:"files"`
+
+	// Dependencies lists the source paths of the workers referenced relative to import.meta.url by the scripts bundled
+	// into File. Each has an entry of its own; other files referenced this way are not emitted and not listed.
+	Dependencies []string `json:"dependencies,omitempty"`
+}
+
+type ManifestFile struct {
+	File string `json:"file"`
+	Hash string `json:"hash"`
+	Size int    `json:"size"`
+}
+
+// NewManifest creates a manifest from the recorded builds of a document and the dependencies found in their scripts.
+// Builds whose entry output has been discarded, e.g. after inlining it, have no entry.
+func NewManifest(records []BuildRecord, deps []DependencyRecord, root, outdir string) (Manifest, error) {
+	manifest := make(Manifest, len(records))
+	for _, record := range records {
+		if record.Output.Entry == "" {
+			continue
+		}
+		meta, err := ParseMetafile(record.Result.Metafile)
+		if err != nil {
+			return nil, err
+		}
+
+		var entry ManifestEntry
+		if entry.File, err = filepath.Rel(outdir, record.Output.Entry); err != nil {
+			return nil, err
+		}
+		entry.File = filepath.ToSlash(entry.File)
+
+		var output MetafileOutput
+		if rel, err := filepath.Rel(root, record.Output.Entry); err == nil {
+			output = meta.Outputs[filepath.ToSlash(rel)]
+		}
+		for _, imp := range output.Imports {
+			if imp.External || imp.Kind != "import-statement" && imp.Kind != "dynamic-import" {
+				continue
+			}
+			if rel, err := filepath.Rel(outdir, filepath.Join(root, imp.Path)); err != nil {
+				return nil, err
+			} else {
+				entry.Imports = append(entry.Imports, filepath.ToSlash(rel))
+			}
+		}
+
+		for _, file := range record.Result.OutputFiles {
+			rel, err := filepath.Rel(outdir, file.Path)
+			if err != nil {
+				return nil, err
+			}
+			entry.Files = append(entry.Files, ManifestFile{filepath.ToSlash(rel), contentHash(file.Contents), len(file.Contents)})
+		}
+
+		seen := make(map[string]bool)
+		for _, dep := range deps {
+			if dep.Kind != DependencyWorker && dep.Kind != DependencySharedWorker {
+				continue
+			}
+			if _, ok := output.Inputs[dep.Importer]; !ok {
+				continue
+			}
+			if source := path.Join(path.Dir(dep.Importer), dep.Path); !seen[source] {
+				seen[source] = true
+				entry.Dependencies = append(entry.Dependencies, source)
+			}
+		}
+		sort.Strings(entry.Dependencies)
+
+		manifest[record.Output.Source] = entry
+	}
+	return manifest, nil
+}
+
+// WriteFile writes the manifest as JSON to path.
+func (m Manifest) WriteFile(path string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
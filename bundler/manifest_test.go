@@ -0,0 +1,83 @@
+package bundler
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestNewManifest(t *testing.T) {
+	root := filepath.FromSlash("/project")
+	outdir := filepath.Join(root, "dist")
+	records := []BuildRecord{
+		{
+			Input: "@/main.ts",
+			Result: api.BuildResult{
+				OutputFiles: []api.OutputFile{
+					{Path: filepath.Join(outdir, "main.js"), Contents: []byte("main")},
+					{Path: filepath.Join(outdir, "chunk.js"), Contents: []byte("chunk")},
+				},
+				Metafile: `{"outputs":{"dist/main.js":{"entryPoint":"src/main.ts",` +
+					`"inputs":{"src/main.ts":{},"src/lib/a.ts":{}},"imports":[` +
+					`{"path":"dist/chunk.js","kind":"import-statement"},` +
+					`{"path":"https://cdn/x.js","kind":"import-statement","external":true}]},` +
+					`"dist/chunk.js":{"inputs":{"src/lib/b.ts":{}}}}}`,
+			},
+			Output: &BuildResult{
+				Source: "src/main.ts",
+				Entry:  filepath.Join(outdir, "main.js"),
+				Files:  []string{filepath.Join(outdir, "chunk.js")},
+			},
+		},
+		{
+			Input: "/src/worker.ts",
+			Result: api.BuildResult{
+				OutputFiles: []api.OutputFile{{Path: filepath.Join(outdir, "worker.js"), Contents: []byte("worker")}},
+				Metafile:    `{"outputs":{"dist/worker.js":{"entryPoint":"src/worker.ts","inputs":{"src/worker.ts":{}}}}}`,
+			},
+			Output: &BuildResult{Source: "src/worker.ts", Entry: filepath.Join(outdir, "worker.js")},
+		},
+		{
+			// an inlined script with a CSS bundle that is still emitted
+			Input: "/src/inlined.js",
+			Result: api.BuildResult{
+				OutputFiles: []api.OutputFile{{Path: filepath.Join(outdir, "inlined.css"), Contents: []byte("css")}},
+				Metafile:    `{"outputs":{"dist/inlined.css":{"inputs":{"src/inlined.css":{}}}}}`,
+			},
+			Output: &BuildResult{Source: "src/inlined.js", CSSBundle: filepath.Join(outdir, "inlined.css")},
+		},
+	}
+	deps := []DependencyRecord{
+		{"src/main.ts", Dependency{Kind: DependencyWorker, Path: "./worker.ts"}},
+		{"src/lib/a.ts", Dependency{Path: "../assets/logo.png"}},
+		{"src/lib/a.ts", Dependency{Path: "./data.wasm"}},
+		{"src/lib/a.ts", Dependency{Kind: DependencySharedWorker, Path: "./worker.ts"}},
+		{"src/main.ts", Dependency{Kind: DependencyWorker, Path: "./worker.ts"}},
+		{"src/other.ts", Dependency{Path: "./other.png"}},
+	}
+
+	got, err := NewManifest(records, deps, root, outdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Manifest{
+		"src/main.ts": {
+			File:    "main.js",
+			Imports: []string{"chunk.js"},
+			Files: []ManifestFile{
+				{"main.js", contentHash([]byte("main")), 4},
+				{"chunk.js", contentHash([]byte("chunk")), 5},
+			},
+			Dependencies: []string{"src/lib/worker.ts", "src/worker.ts"},
+		},
+		"src/worker.ts": {
+			File:  "worker.js",
+			Files: []ManifestFile{{"worker.js", contentHash([]byte("worker")), 6}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,71 @@
+package bundler
+
+import (
+	"encoding/json"
+)
+
+// A Metafile describes the inputs and outputs of a build, as reported by esbuild when BuildOptions.Metafile is set.
+// Paths are relative to the working directory of the build, i.e. the project root.
+type Metafile struct {
+	Inputs  map[string]MetafileInput  `json:"inputs"`
+	Outputs map[string]MetafileOutput `json:"outputs"`
+}
+
+type MetafileInput struct {
+	Bytes   int              `json:"bytes"`
+	Imports []MetafileImport `json:"imports"`
+}
+
+type MetafileOutput struct {
+	Bytes      int                            `json:"bytes"`
+	Inputs     map[string]MetafileOutputInput `json:"inputs"`
+	Imports    []MetafileImport               `json:"imports"`
+	Exports    []string                       `json:"exports"`
+	EntryPoint string                         `json:"entryPoint,omitempty"`
+	CSSBundle  string                         `json:"cssBundle,omitempty"`
+}
+
+type MetafileOutputInput struct {
+	BytesInOutput int `json:"bytesInOutput"`
+}
+
+type MetafileImport struct {
+	Path     string `json:"path"`
+	Kind     string `json:"kind"`
+	External bool   `json:"external,omitempty"`
+}
+
+// ParseMetafile parses the metafile JSON reported by esbuild.
+func ParseMetafile(s string) (*Metafile, error) {
+	var m Metafile
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// MergeMetafiles combines the metafiles of several builds into one, keeping all inputs and outputs as reported.
+func MergeMetafiles(metafiles []string) ([]byte, error) {
+	merged := struct {
+		Inputs  map[string]json.RawMessage `json:"inputs"`
+		Outputs map[string]json.RawMessage `json:"outputs"`
+	}{make(map[string]json.RawMessage), make(map[string]json.RawMessage)}
+
+	for _, s := range metafiles {
+		var m struct {
+			Inputs  map[string]json.RawMessage `json:"inputs"`
+			Outputs map[string]json.RawMessage `json:"outputs"`
+		}
+		if err := json.Unmarshal([]byte(s), &m); err != nil {
+			return nil, err
+		}
+		for path, input := range m.Inputs {
+			merged.Inputs[path] = input
+		}
+		for path, output := range m.Outputs {
+			merged.Outputs[path] = output
+		}
+	}
+
+	return json.MarshalIndent(merged, "", "  ")
+}
@@ -0,0 +1,218 @@
+package bundler
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// defaultAttrs lists attribute values that equal the defaults of their elements and can be omitted.
+var defaultAttrs = map[atom.Atom]map[string]string{
+	atom.Script: {"type": "text/javascript", "language": "javascript"},
+	atom.Style:  {"type": "text/css", "media": "all"},
+	atom.Link:   {"type": "text/css", "media": "all"},
+	atom.Form:   {"method": "get"},
+	atom.Input:  {"type": "text"},
+	atom.Button: {"type": "submit"},
+}
+
+// blockElements lists the elements around which whitespace is insignificant. Whitespace next to any other element,
+// including custom elements, is kept.
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true, atom.Body: true,
+	atom.Caption: true, atom.Col: true, atom.Colgroup: true, atom.Dd: true, atom.Details: true, atom.Dialog: true,
+	atom.Div: true, atom.Dl: true, atom.Dt: true, atom.Fieldset: true, atom.Figcaption: true, atom.Figure: true,
+	atom.Footer: true, atom.Form: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true,
+	atom.H6: true, atom.Head: true, atom.Header: true, atom.Hgroup: true, atom.Hr: true, atom.Html: true,
+	atom.Legend: true, atom.Li: true, atom.Main: true, atom.Menu: true, atom.Nav: true, atom.Ol: true,
+	atom.Optgroup: true, atom.Option: true, atom.P: true, atom.Pre: true, atom.Section: true, atom.Summary: true,
+	atom.Table: true, atom.Tbody: true, atom.Td: true, atom.Tfoot: true, atom.Th: true, atom.Thead: true,
+	atom.Tr: true, atom.Ul: true,
+}
+
+// WriteMinifiedTo minifies the document and writes it to w. Whitespace is collapsed outside of <pre> and <textarea>,
+// comments other than conditional and licence comments are dropped, as are attributes set to their default values,
+// and attribute values are only quoted where necessary.
+func (d *Document) WriteMinifiedTo(w io.Writer) (int64, error) {
+	minifyNode(d.root)
+	return d.write(w, func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+		d.renderMinified(bw, d.root)
+		return bw.Flush()
+	})
+}
+
+func minifyNode(node *html.Node) {
+	if node.Type == html.ElementNode {
+		if defaults, ok := defaultAttrs[node.DataAtom]; ok && node.Namespace == "" {
+			attrs := node.Attr[:0]
+			for _, attr := range node.Attr {
+				if value, ok := defaults[attr.Key]; !ok || !strings.EqualFold(attr.Val, value) {
+					attrs = append(attrs, attr)
+				}
+			}
+			node.Attr = attrs
+		}
+		if preservesWhitespace(node) || isRawTextParent(node) {
+			return
+		}
+	}
+
+	for c := node.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch c.Type {
+		case html.CommentNode:
+			if !isConditionalComment(c.Data) && !isLicenceComment(c.Data) {
+				node.RemoveChild(c)
+			}
+		case html.TextNode:
+			c.Data = collapseWhitespace(c.Data)
+			if isBlockBoundary(c.PrevSibling, node) {
+				c.Data = strings.TrimLeft(c.Data, " ")
+			}
+			if isBlockBoundary(c.NextSibling, node) {
+				c.Data = strings.TrimRight(c.Data, " ")
+			}
+			if c.Data == "" {
+				node.RemoveChild(c)
+			}
+		case html.ElementNode:
+			minifyNode(c)
+		}
+		c = next
+	}
+}
+
+// isBlockBoundary reports whether whitespace next to sibling, or next to the start or end of parent if sibling is nil,
+// is insignificant for rendering.
+func isBlockBoundary(sibling, parent *html.Node) bool {
+	switch {
+	case parent.Type != html.ElementNode:
+		return sibling == nil || isBlockElement(sibling)
+	case parent.DataAtom == atom.Head && parent.Namespace == "":
+		return true // nothing in <head> is rendered
+	case sibling == nil:
+		return isBlockElement(parent)
+	}
+	return isBlockElement(sibling)
+}
+
+func isBlockElement(node *html.Node) bool {
+	return node.Type == html.ElementNode && node.Namespace == "" && blockElements[node.DataAtom]
+}
+
+func preservesWhitespace(node *html.Node) bool {
+	switch node.DataAtom {
+	case atom.Pre, atom.Textarea, atom.Listing, atom.Plaintext:
+		return true
+	}
+	return false
+}
+
+func isConditionalComment(data string) bool {
+	return strings.HasPrefix(data, "[if ") || strings.HasPrefix(data, "<![endif]")
+}
+
+func isLicenceComment(data string) bool {
+	data = strings.ToLower(data)
+	return strings.HasPrefix(data, "!") || strings.Contains(data, "@license") || strings.Contains(data, "@preserve") ||
+		strings.Contains(data, "copyright")
+}
+
+// collapseWhitespace replaces every run of whitespace in s by a single space.
+func collapseWhitespace(s string) string {
+	var b strings.Builder
+	space := false
+	for i := 0; i < len(s); i++ {
+		if isHTMLSpace(s[i]) {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteByte(s[i])
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// renderMinified renders node like html.Render does, but without optional quotes and whitespace.
+func (d *Document) renderMinified(w *bufio.Writer, node *html.Node) {
+	switch node.Type {
+	case html.DoctypeNode:
+		if len(node.Attr) > 0 {
+			html.Render(w, node)
+		} else {
+			w.WriteString("<!doctype " + node.Data + ">")
+		}
+		return
+	case html.CommentNode:
+		w.WriteString("<!--" + node.Data + "-->")
+		return
+	case html.TextNode:
+		if p := node.Parent; p != nil && p.Type == html.ElementNode && isRawTextParent(p) {
+			w.WriteString(node.Data)
+		} else {
+			w.WriteString(escapeText(node.Data))
+		}
+		return
+	case html.ElementNode:
+	default:
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			d.renderMinified(w, c)
+		}
+		return
+	}
+
+	w.WriteString("<" + node.Data)
+	for _, attr := range node.Attr {
+		w.WriteString(" " + attrKey(attr))
+		switch {
+		case attr.Val == "":
+		case node.Namespace == "" && !strings.ContainsAny(attr.Val, " \t\n\f\r\"'=<>`") &&
+			(d.templates == nil || !d.templates.contains(attr.Val)):
+			w.WriteString("=" + strings.ReplaceAll(attr.Val, "&", "&amp;"))
+		default:
+			w.WriteString(`="` + strings.NewReplacer("&", "&amp;", `"`, "&quot;").Replace(attr.Val) + `"`)
+		}
+	}
+	if node.Namespace != "" && node.FirstChild == nil {
+		w.WriteString("/>")
+		return
+	}
+	w.WriteString(">")
+	if node.Namespace == "" && isVoidElement(node.DataAtom) {
+		return
+	}
+	if c := node.FirstChild; c != nil && c.Type == html.TextNode && strings.HasPrefix(c.Data, "\n") {
+		switch node.DataAtom {
+		case atom.Pre, atom.Listing, atom.Textarea:
+			// the parser drops a newline directly following these start tags
+			w.WriteByte('\n')
+		}
+	}
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		d.renderMinified(w, c)
+	}
+	w.WriteString("</" + node.Data + ">")
+}
+
+// isRawTextParent reports whether the text content of node is written without escaping, as html.Render does.
+func isRawTextParent(node *html.Node) bool {
+	switch node.Data {
+	case "iframe", "noembed", "noframes", "noscript", "plaintext", "script", "style", "xmp":
+		return true
+	}
+	return false
+}
+
+func escapeText(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", "\u00a0", "&nbsp;").Replace(s)
+}
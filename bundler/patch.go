@@ -0,0 +1,219 @@
+package bundler
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// An edit replaces the byte range [start, end) of the source text of a document by text.
+type edit struct {
+	start, end int
+	text       string
+}
+
+// errUnlocated reports a change to an element whose start tag could not be located in the source text.
+var errUnlocated = errors.New("changed element not located in source text")
+
+// WriteSourceTo writes the source text of the document with the changes made to its tree applied in place, leaving
+// all other markup byte for byte as it was. Changed, added and removed attributes, the content of scripts and styles,
+// and inserted and removed elements are carried over; other changes to text or comments are not.
+// If an element whose start tag could not be located in the source text was changed or removed, as happens when the
+// parser renames or merges tags, WriteSourceTo falls back to rendering the document like WriteTo.
+func (d *Document) WriteSourceTo(w io.Writer) (int64, error) {
+	edits, err := d.edits()
+	if errors.Is(err, errUnlocated) {
+		return d.WriteTo(w)
+	} else if err != nil {
+		return 0, err
+	}
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start < edits[j].start
+		}
+		// insertions go ahead of the removal of the element they are inserted before
+		return edits[i].start == edits[i].end && edits[j].start != edits[j].end
+	})
+
+	return d.write(w, func(w io.Writer) error {
+		last := 0
+		for _, e := range edits {
+			if e.start < last {
+				continue // within a removed element
+			}
+			if _, err := w.Write(d.source.text[last:e.start]); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, e.text); err != nil {
+				return err
+			}
+			last = e.end
+		}
+		_, err := w.Write(d.source.text[last:])
+		return err
+	})
+}
+
+// edits returns the edits turning the source text into a rendition of the document's current tree.
+func (d *Document) edits() ([]edit, error) {
+	var edits []edit
+	attached := make(map[*html.Node]bool)
+
+	var visit func(*html.Node) error
+	visit = func(node *html.Node) error {
+		attached[node] = true
+		if original, ok := d.unlocated[node]; ok {
+			if !sameAttrs(original.parsedAttrs, node.Attr) ||
+				isRawTextElement(node) && textContent(node) != original.parsedText {
+				return errUnlocated
+			}
+		}
+		element, located := d.elements[node]
+		if located {
+			edits = append(edits, d.attrEdits(element, node.Attr)...)
+			if isRawTextElement(node) && element.close >= 0 {
+				if text := textContent(node); text != element.parsedText {
+					edits = append(edits, edit{element.end, element.close, text})
+				}
+				return nil
+			}
+		}
+
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			if d.parsed[c] {
+				if err := visit(c); err != nil {
+					return err
+				}
+				continue
+			}
+			var b bytes.Buffer
+			if err := html.Render(&b, c); err != nil {
+				return err
+			}
+			offset := d.insertionOffset(c)
+			edits = append(edits, edit{offset, offset, b.String()})
+		}
+		return nil
+	}
+	if err := visit(d.root); err != nil {
+		return nil, err
+	}
+
+	for node := range d.unlocated {
+		if !attached[node] {
+			return nil, errUnlocated
+		}
+	}
+	for node, element := range d.elements {
+		if !attached[node] {
+			end := element.end
+			if element.closeEnd >= 0 {
+				end = element.closeEnd
+			}
+			edits = append(edits, edit{element.start, end, ""})
+		}
+	}
+	return edits, nil
+}
+
+// attrEdits returns the edits turning the attributes of the element's start tag into attrs.
+func (d *Document) attrEdits(element *elementSource, attrs []html.Attribute) []edit {
+	var edits []edit
+	text := d.source.text
+
+	// new attributes are appended to the start tag, ahead of the > or />
+	insert := element.end - 1
+	if insert > element.start && text[insert-1] == '/' {
+		insert--
+	}
+
+	for _, attr := range attrs {
+		key := attrKey(attr)
+		parsed, ok := findAttr(element.parsedAttrs, key)
+		if !ok {
+			edits = append(edits, edit{insert, insert, " " + key + `="` + html.EscapeString(attr.Val) + `"`})
+			continue
+		}
+		src, ok := element.attrs[key]
+		if parsed.Val == attr.Val || !ok {
+			continue
+		}
+		switch {
+		case src.quote != 0:
+			edits = append(edits, edit{src.valueStart, src.valueEnd, html.EscapeString(attr.Val)})
+		case src.valueStart == src.valueEnd:
+			// an attribute without a value, like async
+			edits = append(edits, edit{src.start, src.end, key + `="` + html.EscapeString(attr.Val) + `"`})
+		case attr.Val != "" && !strings.ContainsAny(attr.Val, " \t\n\f\r\"'=<>`&"):
+			edits = append(edits, edit{src.valueStart, src.valueEnd, attr.Val})
+		default:
+			edits = append(edits, edit{src.valueStart, src.valueEnd, `"` + html.EscapeString(attr.Val) + `"`})
+		}
+	}
+
+	for _, parsed := range element.parsedAttrs {
+		key := attrKey(parsed)
+		if _, ok := findAttr(attrs, key); ok {
+			continue
+		}
+		if src, ok := element.attrs[key]; ok {
+			start := src.start
+			for start > element.start && isHTMLSpace(text[start-1]) {
+				start--
+			}
+			edits = append(edits, edit{start, src.end, ""})
+		}
+	}
+	return edits
+}
+
+// insertionOffset returns the offset in the source text at which to insert the node that has no source text itself:
+// ahead of the next sibling in the source text or, if there is none, ahead of the parent's end tag.
+func (d *Document) insertionOffset(node *html.Node) int {
+	for n := node.NextSibling; n != nil; n = n.NextSibling {
+		if offset, ok := d.sourceOffset(n); ok {
+			return offset
+		}
+	}
+	if parent := node.Parent; parent != nil && parent != d.root {
+		if element, ok := d.elements[parent]; ok && element.close >= 0 {
+			return element.close
+		}
+		return d.insertionOffset(parent)
+	}
+	return len(d.source.text)
+}
+
+// sourceOffset returns the offset of the first start tag in the source text belonging to node or its descendants.
+func (d *Document) sourceOffset(node *html.Node) (int, bool) {
+	if element, ok := d.elements[node]; ok {
+		return element.start, true
+	}
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		if offset, ok := d.sourceOffset(c); ok {
+			return offset, true
+		}
+	}
+	return 0, false
+}
+
+// attrKey returns the name of the attribute as written in the source text.
+func attrKey(attr html.Attribute) string {
+	if attr.Namespace != "" {
+		return attr.Namespace + ":" + attr.Key
+	}
+	return attr.Key
+}
+
+func findAttr(attrs []html.Attribute, key string) (html.Attribute, bool) {
+	for _, attr := range attrs {
+		if attrKey(attr) == key {
+			return attr, true
+		}
+	}
+	return html.Attribute{}, false
+}
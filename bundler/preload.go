@@ -0,0 +1,177 @@
+package bundler
+
+import (
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// fontTypes maps the extensions of web fonts to their MIME types.
+var fontTypes = map[string]string{
+	".woff2": "font/woff2",
+	".woff":  "font/woff",
+	".ttf":   "font/ttf",
+	".otf":   "font/otf",
+}
+
+// AddPreloadHints inserts preload hints into <head>, ahead of the first script or link but after the import map:
+// <link rel="modulepreload"> for the chunks module scripts statically import and for the externals they import via
+// the import map, <link rel="preload" as="font"> for fonts referenced by stylesheets, and <link rel="preload"
+// as="image"> for images marked as critical by fetchpriority="high".
+func (d *Document) AddPreloadHints(records []BuildRecord, root, outdir string) error {
+	outputs, err := metafileOutputs(records, root)
+	if err != nil {
+		return err
+	}
+	imports := d.importMap().Imports
+
+	var hints []*html.Node
+	existing := make(map[string]bool)
+	add := func(attrs ...html.Attribute) {
+		href := attrs[1].Val
+		if !existing[href] {
+			existing[href] = true
+			hints = append(hints, &html.Node{Type: html.ElementNode, Data: "link", DataAtom: atom.Link, Attr: attrs})
+		}
+	}
+	resolve := func(ref string) (string, bool) {
+		path, ok := outputFile(outdir, ref)
+		_, known := outputs[path]
+		return path, ok && known
+	}
+
+	var nodes []*html.Node
+	var visit func(*html.Node)
+	visit = func(node *html.Node) {
+		if node.Type == html.ElementNode && node.Namespace == "" {
+			nodes = append(nodes, node)
+			if node.DataAtom == atom.Link && (hasRel(node, "preload") || hasRel(node, "modulepreload")) {
+				existing[getAttr(node, "href")] = true
+			}
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(d.root)
+
+	for _, node := range nodes {
+		switch {
+		case node.DataAtom == atom.Script && strings.EqualFold(getAttr(node, "type"), "module"):
+			path, ok := resolve(getAttr(node, "src"))
+			if !ok {
+				continue
+			}
+			seen := map[string]bool{path: true}
+			for queue := []string{path}; len(queue) > 0; queue = queue[1:] {
+				for _, imp := range outputs[queue[0]].Imports {
+					if imp.Kind != "import-statement" {
+						continue
+					}
+					if imp.External {
+						if target, ok := imports[imp.Path]; ok {
+							add(html.Attribute{Key: "rel", Val: "modulepreload"}, html.Attribute{Key: "href", Val: target})
+						}
+						continue
+					}
+					chunk := filepath.Join(root, imp.Path)
+					if seen[chunk] {
+						continue
+					}
+					seen[chunk] = true
+					queue = append(queue, chunk)
+					if href, err := relativeURL(outdir, chunk); err == nil {
+						add(html.Attribute{Key: "rel", Val: "modulepreload"}, html.Attribute{Key: "href", Val: href})
+					}
+				}
+			}
+		case node.DataAtom == atom.Link && hasRel(node, "stylesheet"):
+			path, ok := resolve(getAttr(node, "href"))
+			if !ok {
+				continue
+			}
+			for _, imp := range outputs[path].Imports {
+				typ, ok := fontTypes[strings.ToLower(filepath.Ext(imp.Path))]
+				if imp.External || imp.Kind != "url-token" || !ok {
+					continue
+				}
+				if href, err := relativeURL(outdir, filepath.Join(root, imp.Path)); err == nil {
+					// fonts are fetched in CORS mode, so their preloads must be, too
+					add(html.Attribute{Key: "rel", Val: "preload"}, html.Attribute{Key: "href", Val: href},
+						html.Attribute{Key: "as", Val: "font"}, html.Attribute{Key: "type", Val: typ},
+						html.Attribute{Key: "crossorigin"})
+				}
+			}
+		case node.DataAtom == atom.Img && strings.EqualFold(getAttr(node, "fetchpriority"), "high"):
+			if src := getAttr(node, "src"); src != "" && !strings.HasPrefix(src, "data:") {
+				add(html.Attribute{Key: "rel", Val: "preload"}, html.Attribute{Key: "href", Val: src},
+					html.Attribute{Key: "as", Val: "image"}, html.Attribute{Key: "fetchpriority", Val: "high"})
+			}
+		}
+	}
+	if len(hints) == 0 {
+		return nil
+	}
+
+	head := d.head()
+	next := findElement(head, func(n *html.Node) bool {
+		return n.Parent == head && (n.DataAtom == atom.Script && getAttr(n, "type") != "importmap" || n.DataAtom == atom.Link)
+	})
+	for _, hint := range hints {
+		head.InsertBefore(hint, next)
+	}
+	return nil
+}
+
+// metafileOutputs returns the outputs of the recorded builds by their absolute paths, given the project root.
+func metafileOutputs(records []BuildRecord, root string) (map[string]MetafileOutput, error) {
+	outputs := make(map[string]MetafileOutput)
+	for _, record := range records {
+		meta, err := ParseMetafile(record.Result.Metafile)
+		if err != nil {
+			return nil, err
+		}
+		for path, output := range meta.Outputs {
+			outputs[filepath.Join(root, path)] = output
+		}
+	}
+	return outputs, nil
+}
+
+// outputFile returns the path of the file in dir that the reference from a document in dir resolves to, unless ref
+// refers to an external resource.
+func outputFile(dir, ref string) (string, bool) {
+	u, err := url.Parse(ref)
+	if ref == "" || isExternal(ref) || err != nil || u.Path == "" {
+		return "", false
+	}
+	return filepath.Join(dir, filepath.FromSlash(u.Path)), true
+}
+
+// importMap returns the import map of the document or an empty one if it has none.
+func (d *Document) importMap() *ImportMap {
+	m := &ImportMap{}
+	node := findElement(d.root, func(n *html.Node) bool {
+		return n.DataAtom == atom.Script && getAttr(n, "type") == "importmap"
+	})
+	if node != nil {
+		json.Unmarshal([]byte(d.restoreText(textContent(node))), m)
+	}
+	return m
+}
+
+// relativeURL returns the URL of the file at path relative to a document or script in dir.
+func relativeURL(dir, path string) (string, error) {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return "", err
+	}
+	if rel = filepath.ToSlash(rel); strings.HasPrefix(rel, "../") {
+		return rel, nil
+	}
+	return "./" + rel, nil
+}
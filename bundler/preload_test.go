@@ -0,0 +1,149 @@
+package bundler
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestDocumentAddPreloadHints(t *testing.T) {
+	root := filepath.FromSlash("/project")
+	outdir := filepath.Join(root, "dist")
+	records := []BuildRecord{
+		{Result: api.BuildResult{Metafile: `{"outputs":{` +
+			`"dist/main.js":{"imports":[{"path":"dist/chunk.js","kind":"import-statement"},` +
+			`{"path":"dist/lazy.js","kind":"dynamic-import"},{"path":"lodash","kind":"import-statement","external":true},` +
+			`{"path":"react","kind":"import-statement","external":true}]},` +
+			`"dist/chunk.js":{"imports":[{"path":"dist/shared.js","kind":"import-statement"},` +
+			`{"path":"dist/main.js","kind":"import-statement"}]},` +
+			`"dist/shared.js":{},"dist/lazy.js":{}}}`}},
+		{Result: api.BuildResult{Metafile: `{"outputs":{"dist/style.css":{"imports":[` +
+			`{"path":"dist/font.woff2","kind":"url-token"},{"path":"dist/bg.png","kind":"url-token"},` +
+			`{"path":"https://fonts/x.woff","kind":"url-token","external":true}]}}}`}},
+	}
+	importMap := `<script type="importmap">{"imports":{"lodash":"https://cdn/lodash.js"}}</script>`
+
+	tcs := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			"module chunks and externals",
+			`<head>` + importMap + `<script type="module" src="./main.js"></script></head>`,
+			`<head>` + importMap + `<link rel="modulepreload" href="./chunk.js"/>` +
+				`<link rel="modulepreload" href="https://cdn/lodash.js"/><link rel="modulepreload" href="./shared.js"/>` +
+				`<script type="module" src="./main.js"></script></head>`,
+		},
+		{
+			"classic script",
+			`<head><script src="./main.js"></script></head>`,
+			`<head><script src="./main.js"></script></head>`,
+		},
+		{
+			"fonts",
+			`<head><title>t</title><link rel="stylesheet" href="./style.css"/></head>`,
+			`<head><title>t</title><link rel="preload" href="./font.woff2" as="font" type="font/woff2" crossorigin=""/>` +
+				`<link rel="stylesheet" href="./style.css"/></head>`,
+		},
+		{
+			"critical images",
+			`<head></head><body><img src="a.png" fetchpriority="high"/><img src="b.png"/>` +
+				`<img src="data:image/png;base64,AA" fetchpriority="high"/></body>`,
+			`<head><link rel="preload" href="a.png" as="image" fetchpriority="high"/></head>` +
+				`<body><img src="a.png" fetchpriority="high"/><img src="b.png"/>` +
+				`<img src="data:image/png;base64,AA" fetchpriority="high"/></body>`,
+		},
+		{
+			"existing hints",
+			`<head><link rel="modulepreload" href="./chunk.js"/><script type="module" src="./main.js"></script></head>`,
+			`<head><link rel="modulepreload" href="./shared.js"/><link rel="modulepreload" href="./chunk.js"/>` +
+				`<script type="module" src="./main.js"></script></head>`,
+		},
+		{
+			"unknown output",
+			`<head><script type="module" src="./other.js"></script><link rel="stylesheet" href="https://cdn/x.css"/></head>`,
+			`<head><script type="module" src="./other.js"></script><link rel="stylesheet" href="https://cdn/x.css"/></head>`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := NewDocument(strings.NewReader(tc.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := doc.AddPreloadHints(records, root, outdir); err != nil {
+				t.Fatal(err)
+			}
+			var b strings.Builder
+			if _, err := doc.WriteTo(&b); err != nil {
+				t.Fatal(err)
+			}
+			want := "<html>" + tc.want + "</html>"
+			if !strings.Contains(tc.want, "<body>") {
+				want = "<html>" + tc.want + "<body></body></html>"
+			}
+			if got := b.String(); got != want {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestOutputFile(t *testing.T) {
+	dir := filepath.FromSlash("/project/dist")
+
+	tcs := []struct {
+		ref  string
+		want string
+	}{
+		{"./main.js", "/project/dist/main.js"},
+		{"main.js?v=1#x", "/project/dist/main.js"},
+		{"assets/a%20b.png", "/project/dist/assets/a b.png"},
+		{"/main.js", "/project/dist/main.js"},
+		{"../main.js", "/project/main.js"},
+		{"https://cdn/main.js", ""},
+		{"//cdn/main.js", ""},
+		{"data:text/css,p{}", ""},
+		{"#x", ""},
+		{"", ""},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.ref, func(t *testing.T) {
+			got, ok := outputFile(dir, tc.ref)
+			if want := filepath.FromSlash(tc.want); got != want || ok != (tc.want != "") {
+				t.Errorf("got %q, %t, want %q", got, ok, want)
+			}
+		})
+	}
+}
+
+func TestMetafileOutputs(t *testing.T) {
+	root := filepath.FromSlash("/project")
+	records := []BuildRecord{
+		{Result: api.BuildResult{Metafile: `{"outputs":{"dist/a.js":{"bytes":1},"dist/a.css":{"bytes":2}}}`}},
+		{Result: api.BuildResult{Metafile: `{"outputs":{"dist/b.js":{"bytes":3}}}`}},
+	}
+
+	outputs, err := metafileOutputs(records, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, bytes := range map[string]int{"dist/a.js": 1, "dist/a.css": 2, "dist/b.js": 3} {
+		if got := outputs[filepath.Join(root, filepath.FromSlash(path))].Bytes; got != bytes {
+			t.Errorf("got %d bytes for %s, want %d", got, path, bytes)
+		}
+	}
+	if len(outputs) != 3 {
+		t.Errorf("got %d outputs, want 3", len(outputs))
+	}
+
+	records = append(records, BuildRecord{Result: api.BuildResult{Metafile: "{"}})
+	if _, err := metafileOutputs(records, root); err == nil {
+		t.Error("got no error for an invalid metafile")
+	}
+}
@@ -0,0 +1,131 @@
+package bundler
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+
+	"github.com/dlw93/cvbuild/util"
+)
+
+// A BuildRecord is the result of building a single input.
+type BuildRecord struct {
+	Input    string
+	Result   api.BuildResult
+	Duration time.Duration
+
+	// Output identifies the outputs of a successful build; it is nil if the build failed.
+	Output *BuildResult
+}
+
+// A BuildRecorder collects the results of all builds performed for a document, including nested worker builds, and
+// the dependencies found in their scripts. It is safe for concurrent use.
+type BuildRecorder struct {
+	mu      sync.Mutex
+	records []BuildRecord
+	deps    []DependencyRecord
+}
+
+// A DependencyRecord is a dependency found in the script at Importer, a path relative to the project root.
+type DependencyRecord struct {
+	Importer string
+	Dependency
+}
+
+// Record is a ResultHandler that appends the record to the recorder.
+func (r *BuildRecorder) Record(record BuildRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, record)
+}
+
+// RecordDependency appends the dependency found in the script at importer to the recorder.
+func (r *BuildRecorder) RecordDependency(importer string, dep Dependency) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deps = append(r.deps, DependencyRecord{importer, dep})
+}
+
+// Dependencies returns all dependencies in the order they were recorded.
+func (r *BuildRecorder) Dependencies() []Dependency {
+	return util.Map(r.DependencyRecords(), func(dep DependencyRecord) Dependency { return dep.Dependency })
+}
+
+// DependencyRecords returns all dependencies with the scripts they were found in, in the order they were recorded.
+func (r *BuildRecorder) DependencyRecords() []DependencyRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]DependencyRecord(nil), r.deps...)
+}
+
+// Discard removes the output file at path from all records, e.g. after it has been inlined. If the file is the entry
+// output or CSS bundle of a build, its Output.Entry or Output.CSSBundle is cleared.
+func (r *BuildRecorder) Discard(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, record := range r.records {
+		r.records[i].Result.OutputFiles = util.Filter(record.Result.OutputFiles, func(file api.OutputFile) bool {
+			return file.Path != path
+		})
+		if record.Output != nil {
+			output := *record.Output
+			output.Files = util.Filter(output.Files, func(file string) bool { return file != path })
+			if output.Entry == path {
+				output.Entry = ""
+			}
+			if output.CSSBundle == path {
+				output.CSSBundle = ""
+			}
+			r.records[i].Output = &output
+		}
+	}
+}
+
+// Imported reports whether any recorded output imports the output file at path, given the project root.
+func (r *BuildRecorder) Imported(path, root string) (bool, error) {
+	for _, record := range r.Records() {
+		meta, err := ParseMetafile(record.Result.Metafile)
+		if err != nil {
+			return false, err
+		}
+		for _, output := range meta.Outputs {
+			for _, imp := range output.Imports {
+				if !imp.External && filepath.Join(root, imp.Path) == path {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
+// All returns all records in the order they were recorded.
+func (r *BuildRecorder) All() []BuildRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]BuildRecord(nil), r.records...)
+}
+
+// Records returns the records of all successful builds in the order they were recorded.
+func (r *BuildRecorder) Records() []BuildRecord {
+	return util.Filter(r.All(), func(record BuildRecord) bool {
+		return record.Output != nil && len(record.Result.OutputFiles) > 0
+	})
+}
+
+// Outputs returns the paths of all recorded output files without duplicates.
+func (r *BuildRecorder) Outputs() []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, record := range r.Records() {
+		for _, file := range record.Result.OutputFiles {
+			if !seen[file.Path] {
+				seen[file.Path] = true
+				paths = append(paths, file.Path)
+			}
+		}
+	}
+	return paths
+}
@@ -0,0 +1,73 @@
+package bundler
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"golang.org/x/exp/slices"
+)
+
+func TestBuildRecorderImported(t *testing.T) {
+	root := filepath.FromSlash("/project")
+	path := func(name string) string { return filepath.Join(root, "dist", name) }
+
+	var r BuildRecorder
+	r.Record(BuildRecord{
+		Input: "/src/style.css",
+		Result: api.BuildResult{
+			OutputFiles: []api.OutputFile{{Path: path("style.css")}, {Path: path("bg.png")}},
+			Metafile: `{"outputs":{"dist/style.css":{"imports":[{"path":"dist/bg.png","kind":"url-token"},` +
+				`{"path":"https://cdn/x.png","kind":"url-token","external":true}]},"dist/bg.png":{}}}`,
+		},
+		Output: &BuildResult{Source: "src/style.css", Entry: path("style.css"), Files: []string{path("bg.png")}},
+	})
+	r.Record(BuildRecord{
+		Input: "/src/logo.png",
+		Result: api.BuildResult{
+			OutputFiles: []api.OutputFile{{Path: path("logo.png")}},
+			Metafile:    `{"outputs":{"dist/logo.png":{}}}`,
+		},
+		Output: &BuildResult{Entry: path("logo.png")},
+	})
+	r.Record(BuildRecord{Input: "/src/broken.js", Result: api.BuildResult{Errors: []api.Message{{Text: "broken"}}}})
+
+	tcs := []struct {
+		path string
+		want bool
+	}{
+		{path("bg.png"), true},
+		{path("logo.png"), false},
+		{path("style.css"), false},
+	}
+	for _, tc := range tcs {
+		t.Run(filepath.Base(tc.path), func(t *testing.T) {
+			if got, err := r.Imported(tc.path, root); err != nil {
+				t.Fatal(err)
+			} else if got != tc.want {
+				t.Errorf("got %t, want %t", got, tc.want)
+			}
+		})
+	}
+
+	// an inlined image that no output imports is removed from the records, one still imported stays
+	r.Discard(path("logo.png"))
+	if got, want := r.Outputs(), []string{path("style.css"), path("bg.png")}; !slices.Equal(got, want) {
+		t.Errorf("got outputs %q, want %q", got, want)
+	}
+	if got := len(r.Records()); got != 1 {
+		t.Errorf("got %d records, want 1", got)
+	}
+	if got := len(r.All()); got != 3 {
+		t.Errorf("got %d records in all, want 3", got)
+	}
+
+	r.Discard(path("bg.png"))
+	if got := r.Records()[0].Output; got.Source != "src/style.css" || got.Entry != path("style.css") || len(got.Files) != 0 {
+		t.Errorf("got %+v, want the source and entry kept and no files", got)
+	}
+	r.Discard(path("style.css"))
+	if got := r.All()[0].Output; got.Source != "src/style.css" || got.Entry != "" {
+		t.Errorf("got %+v, want the source kept and the entry cleared", got)
+	}
+}
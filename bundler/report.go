@@ -0,0 +1,233 @@
+package bundler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/andybalholm/brotli"
+)
+
+// topModules is the number of contributing modules listed per output in a size report.
+const topModules = 5
+
+// An OutputSize holds the raw and compressed sizes of an output file.
+type OutputSize struct {
+	Input string // the entry point the output was built for
+	File  string // the output path relative to the output directory
+
+	Raw, Gzip, Brotli int
+
+	// Modules lists the inputs contributing the most bytes to the output in descending order.
+	Modules []ModuleSize
+}
+
+type ModuleSize struct {
+	Path  string
+	Bytes int
+}
+
+// A Budget limits the total size of all outputs of an entry point. Zero values impose no limit.
+type Budget struct {
+	Raw    Size `json:"raw"`
+	Gzip   Size `json:"gzip"`
+	Brotli Size `json:"brotli"`
+}
+
+// A Size is a number of bytes that can be given in JSON either as a number or as a string with a unit, e.g. "150kB".
+type Size int
+
+// A BudgetError reports that the outputs of an entry point exceed their budget.
+type BudgetError struct {
+	Input       string
+	Compression string
+	Size, Limit int
+}
+
+// NewSizeReport measures all outputs of the recorded builds.
+func NewSizeReport(records []BuildRecord, root, outdir string) ([]OutputSize, error) {
+	var sizes []OutputSize
+	for _, record := range records {
+		meta, err := ParseMetafile(record.Result.Metafile)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range record.Result.OutputFiles {
+			size := OutputSize{Input: record.Input, Raw: len(file.Contents)}
+			if size.File, err = filepath.Rel(outdir, file.Path); err != nil {
+				return nil, err
+			}
+			size.File = filepath.ToSlash(size.File)
+			if size.Gzip, err = gzipSize(file.Contents); err != nil {
+				return nil, err
+			}
+			if size.Brotli, err = brotliSize(file.Contents); err != nil {
+				return nil, err
+			}
+			if rel, err := filepath.Rel(root, file.Path); err == nil {
+				size.Modules = topContributors(meta.Outputs[filepath.ToSlash(rel)], topModules)
+			}
+			sizes = append(sizes, size)
+		}
+	}
+	return sizes, nil
+}
+
+// WriteSizeReport writes the sizes as a table to w.
+func WriteSizeReport(w io.Writer, sizes []OutputSize) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Output\tRaw\tGzip\tBrotli\t")
+	for _, size := range sizes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", size.File, formatSize(size.Raw), formatSize(size.Gzip), formatSize(size.Brotli))
+		for _, module := range size.Modules {
+			fmt.Fprintf(tw, "  %s\t%s\t\t\t\n", module.Path, formatSize(module.Bytes))
+		}
+	}
+	return tw.Flush()
+}
+
+// CheckBudgets returns an error for every entry point whose outputs exceed its budget in total.
+func CheckBudgets(sizes []OutputSize, budgets map[string]Budget) []error {
+	totals := make(map[string]*OutputSize)
+	for _, size := range sizes {
+		total, ok := totals[size.Input]
+		if !ok {
+			total = &OutputSize{Input: size.Input}
+			totals[size.Input] = total
+		}
+		total.Raw += size.Raw
+		total.Gzip += size.Gzip
+		total.Brotli += size.Brotli
+	}
+
+	inputs := make([]string, 0, len(budgets))
+	for input := range budgets {
+		inputs = append(inputs, input)
+	}
+	sort.Strings(inputs)
+
+	var errs []error
+	for _, input := range inputs {
+		budget, total := budgets[input], totals[input]
+		if total == nil {
+			continue
+		}
+		for _, c := range []struct {
+			compression string
+			size        int
+			limit       Size
+		}{
+			{"raw", total.Raw, budget.Raw},
+			{"gzip", total.Gzip, budget.Gzip},
+			{"brotli", total.Brotli, budget.Brotli},
+		} {
+			if c.limit > 0 && c.size > int(c.limit) {
+				errs = append(errs, &BudgetError{input, c.compression, c.size, int(c.limit)})
+			}
+		}
+	}
+	return errs
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("%s exceeds its %s budget of %s by %s", e.Input, e.Compression, formatSize(e.Limit), formatSize(e.Size-e.Limit))
+}
+
+var sizeUnits = map[string]int{
+	"":    1,
+	"b":   1,
+	"kb":  1000,
+	"kib": 1 << 10,
+	"mb":  1000 * 1000,
+	"mib": 1 << 20,
+}
+
+func (s *Size) UnmarshalJSON(b []byte) error {
+	var n int
+	if err := json.Unmarshal(b, &n); err == nil {
+		*s = Size(n)
+		return nil
+	}
+
+	var str string
+	if err := json.Unmarshal(b, &str); err != nil {
+		return err
+	}
+	str = strings.TrimSpace(str)
+	i := strings.IndexFunc(str, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(str)
+	}
+	unit, ok := sizeUnits[strings.ToLower(strings.TrimSpace(str[i:]))]
+	if !ok {
+		return fmt.Errorf("unknown size unit in %q", str)
+	}
+	f, err := strconv.ParseFloat(str[:i], 64)
+	if err != nil {
+		return fmt.Errorf("invalid size %q", str)
+	}
+	*s = Size(f * float64(unit))
+	return nil
+}
+
+func topContributors(output MetafileOutput, n int) []ModuleSize {
+	modules := make([]ModuleSize, 0, len(output.Inputs))
+	for path, input := range output.Inputs {
+		modules = append(modules, ModuleSize{path, input.BytesInOutput})
+	}
+	sort.Slice(modules, func(i, j int) bool {
+		if modules[i].Bytes != modules[j].Bytes {
+			return modules[i].Bytes > modules[j].Bytes
+		}
+		return modules[i].Path < modules[j].Path
+	})
+	if len(modules) > n {
+		modules = modules[:n]
+	}
+	return modules
+}
+
+func gzipSize(b []byte) (int, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := w.Write(b); err != nil {
+		return 0, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+	return buf.Len(), nil
+}
+
+func brotliSize(b []byte) (int, error) {
+	var buf bytes.Buffer
+	w := brotli.NewWriterLevel(&buf, brotli.BestCompression)
+	if _, err := w.Write(b); err != nil {
+		return 0, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+	return buf.Len(), nil
+}
+
+func formatSize(n int) string {
+	switch {
+	case n < 1000:
+		return fmt.Sprintf("%d B", n)
+	case n < 1000*1000:
+		return fmt.Sprintf("%.1f kB", float64(n)/1000)
+	default:
+		return fmt.Sprintf("%.2f MB", float64(n)/(1000*1000))
+	}
+}
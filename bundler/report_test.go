@@ -0,0 +1,135 @@
+package bundler
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"golang.org/x/exp/slices"
+)
+
+func TestSizeUnmarshalJSON(t *testing.T) {
+	tcs := []struct {
+		json string
+		want Size
+	}{
+		{`1234`, 1234},
+		{`"1234"`, 1234},
+		{`"150kB"`, 150000},
+		{`"1.5 MB"`, 1500000},
+		{`"2KiB"`, 2048},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.json, func(t *testing.T) {
+			var got Size
+			if err := json.Unmarshal([]byte(tc.json), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got %d, want %d", got, tc.want)
+			}
+		})
+	}
+
+	var s Size
+	if err := json.Unmarshal([]byte(`"10 parsecs"`), &s); err == nil {
+		t.Errorf("got %d, want error", s)
+	}
+}
+
+func TestNewSizeReport(t *testing.T) {
+	root := filepath.FromSlash("/project")
+	outdir := filepath.Join(root, "dist")
+	main := strings.Repeat("console.log('main');", 50)
+	records := []BuildRecord{{
+		Input: "/src/main.js",
+		Result: api.BuildResult{
+			OutputFiles: []api.OutputFile{
+				{Path: filepath.Join(outdir, "main.js"), Contents: []byte(main)},
+				{Path: filepath.Join(outdir, "main.css"), Contents: []byte("p{margin:0}")},
+			},
+			Metafile: `{"outputs":{"dist/main.js":{"entryPoint":"src/main.js","inputs":{` +
+				`"src/main.js":{"bytesInOutput":600},"src/a.js":{"bytesInOutput":200},"src/b.js":{"bytesInOutput":200},` +
+				`"src/c.js":{"bytesInOutput":10},"src/d.js":{"bytesInOutput":5},"src/e.js":{"bytesInOutput":1}}},` +
+				`"dist/main.css":{"inputs":{"src/main.css":{"bytesInOutput":11}}}}}`,
+		},
+	}}
+
+	sizes, err := NewSizeReport(records, root, outdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 2 {
+		t.Fatalf("got %d sizes, want 2", len(sizes))
+	}
+
+	js, css := sizes[0], sizes[1]
+	if js.Input != "/src/main.js" || js.File != "main.js" || js.Raw != len(main) {
+		t.Errorf("got %s of %s with %d bytes, want main.js of /src/main.js with %d bytes", js.File, js.Input, js.Raw, len(main))
+	}
+	if js.Gzip <= 0 || js.Gzip >= js.Raw || js.Brotli <= 0 || js.Brotli >= js.Raw {
+		t.Errorf("got gzip %d and brotli %d bytes for %d compressible bytes", js.Gzip, js.Brotli, js.Raw)
+	}
+	want := []ModuleSize{{"src/main.js", 600}, {"src/a.js", 200}, {"src/b.js", 200}, {"src/c.js", 10}, {"src/d.js", 5}}
+	if !slices.Equal(js.Modules, want) {
+		t.Errorf("got modules %v, want %v", js.Modules, want)
+	}
+	if css.File != "main.css" || css.Raw != 11 || !slices.Equal(css.Modules, []ModuleSize{{"src/main.css", 11}}) {
+		t.Errorf("got %+v for main.css", css)
+	}
+
+	records[0].Result.Metafile = "{"
+	if _, err := NewSizeReport(records, root, outdir); err == nil {
+		t.Error("got no error for an invalid metafile")
+	}
+}
+
+func TestCheckBudgets(t *testing.T) {
+	sizes := []OutputSize{
+		{Input: "/a.js", File: "a.js", Raw: 1000, Gzip: 400, Brotli: 300},
+		{Input: "/a.js", File: "a.css", Raw: 500, Gzip: 200, Brotli: 100},
+		{Input: "/b.js", File: "b.js", Raw: 100, Gzip: 80, Brotli: 70},
+	}
+
+	tcs := []struct {
+		name    string
+		budgets map[string]Budget
+		want    []BudgetError
+	}{
+		{"no budgets", nil, nil},
+		{"within budget", map[string]Budget{"/a.js": {Raw: 1500, Gzip: 600, Brotli: 400}}, nil},
+		{"unlimited", map[string]Budget{"/a.js": {}}, nil},
+		{"unknown input", map[string]Budget{"/c.js": {Raw: 1}}, nil},
+		{
+			"totals exceeded",
+			map[string]Budget{"/a.js": {Raw: 1499, Brotli: 399}},
+			[]BudgetError{{"/a.js", "raw", 1500, 1499}, {"/a.js", "brotli", 400, 399}},
+		},
+		{
+			"several inputs",
+			map[string]Budget{"/b.js": {Gzip: 50}, "/a.js": {Gzip: 500}},
+			[]BudgetError{{"/a.js", "gzip", 600, 500}, {"/b.js", "gzip", 80, 50}},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := CheckBudgets(sizes, tc.budgets)
+			var got []BudgetError
+			for _, err := range errs {
+				var e *BudgetError
+				if !errors.As(err, &e) {
+					t.Fatalf("got %v, want a *BudgetError", err)
+				}
+				got = append(got, *e)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
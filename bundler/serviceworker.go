@@ -0,0 +1,124 @@
+package bundler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PrecacheManifestPlaceholder is the expression in a user-provided service worker that is replaced by the precache manifest.
+const PrecacheManifestPlaceholder = "self.__PRECACHE_MANIFEST"
+
+// A PrecacheEntry is a file listed in the precache manifest of a service worker.
+type PrecacheEntry struct {
+	URL      string `json:"url"`
+	Revision string `json:"revision"`
+}
+
+// NewPrecacheManifest lists the files at the given paths by their URL relative to outdir and the hash of their content.
+func NewPrecacheManifest(paths []string, outdir string) ([]PrecacheEntry, error) {
+	manifest := make([]PrecacheEntry, 0, len(paths))
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(outdir, path)
+		if err != nil {
+			return nil, err
+		}
+		manifest = append(manifest, PrecacheEntry{"./" + filepath.ToSlash(rel), contentHash(b)})
+	}
+	sort.Slice(manifest, func(i, j int) bool { return manifest[i].URL < manifest[j].URL })
+	return manifest, nil
+}
+
+// BuildServiceWorker bundles the service worker configured by config with the manifest defined as
+// PrecacheManifestPlaceholder or, if config has no source, writes a generated one to the output directory.
+// config.Filename, if set, must be a local path ending in .js.
+func BuildServiceWorker(ctx context.Context, config *ServiceWorkerConfig, manifest []PrecacheEntry, options BuildOptions) error {
+	name := filepath.FromSlash(config.Filename)
+	if name != "" && (!filepath.IsLocal(name) || filepath.Ext(name) != ".js") {
+		return fmt.Errorf("cannot write the service worker to %s: the filename must be a path ending in .js "+
+			"inside the output directory", config.Filename)
+	}
+
+	if config.Source == "" {
+		if name == "" {
+			name = "sw.js"
+		}
+		outdir := options.OutputDirectory
+		if !filepath.IsAbs(outdir) {
+			outdir = filepath.Join(options.ProjectRootAbsolute, outdir)
+		}
+		path := filepath.Join(outdir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		return WriteServiceWorker(path, manifest)
+	}
+
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if name != "" {
+		options.entryName = strings.TrimSuffix(filepath.ToSlash(name), ".js")
+	}
+	define := make(map[string]string, len(options.Define)+1)
+	for k, v := range options.Define {
+		define[k] = v
+	}
+	define[PrecacheManifestPlaceholder] = string(b)
+	options.Define = define
+	_, err = Build(ctx, "/"+filepath.ToSlash(filepath.Clean(config.Source)), options)
+	return err
+}
+
+// WriteServiceWorker writes a service worker to path that precaches the files in the manifest and serves them cache-first.
+func WriteServiceWorker(path string, manifest []PrecacheEntry) error {
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	revision := sha256.New()
+	for _, entry := range manifest {
+		revision.Write([]byte(entry.URL + "\x00" + entry.Revision + "\x00"))
+	}
+	cache := "cvbuild-" + hex.EncodeToString(revision.Sum(nil))[:16]
+	return os.WriteFile(path, []byte(fmt.Sprintf(serviceWorkerTemplate, b, cache)), 0o644)
+}
+
+// contentHash returns a short hexadecimal hash of b.
+func contentHash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
+
+const serviceWorkerTemplate = `const manifest = %s;
+const cacheName = %q;
+
+self.addEventListener("install", (event) => {
+	self.skipWaiting();
+	event.waitUntil(caches.open(cacheName).then((cache) => cache.addAll(manifest.map((entry) => entry.url))));
+});
+
+self.addEventListener("activate", (event) => {
+	event.waitUntil(caches.keys().then((keys) => Promise.all(
+		keys.filter((key) => key.startsWith("cvbuild-") && key !== cacheName).map((key) => caches.delete(key)),
+	)));
+});
+
+self.addEventListener("fetch", (event) => {
+	if (event.request.method !== "GET") {
+		return;
+	}
+	event.respondWith(caches.match(event.request, { ignoreSearch: true }).then((response) => response || fetch(event.request)));
+});
+`
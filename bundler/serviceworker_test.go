@@ -0,0 +1,75 @@
+package bundler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildServiceWorker(t *testing.T) {
+	manifest := []PrecacheEntry{{"./main.js", "0123456789abcdef"}}
+
+	tcs := []struct {
+		name     string
+		source   string
+		filename string
+		want     string // the output relative to the output directory, or empty if an error is expected
+	}{
+		{"default", "src/sw.js", "", "sw.js"},
+		{"filename", "src/sw.js", "service-worker.js", "service-worker.js"},
+		{"subdirectory", "src/sw.js", "workers/sw.js", "workers/sw.js"},
+		{"extension", "src/sw.js", "sw.mjs", ""},
+		{"outside", "src/sw.js", "../sw.js", ""},
+		{"absolute", "src/sw.js", "/sw.js", ""},
+		{"generated", "", "", "sw.js"},
+		{"generated subdirectory", "", "workers/sw.js", "workers/sw.js"},
+		{"generated outside", "", "../sw.js", ""},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			root := writeFiles(t, t.TempDir(), map[string]string{
+				"src/sw.js": "const manifest = " + PrecacheManifestPlaceholder + "; console.log(manifest, DEBUG);",
+			})
+			var records []BuildRecord
+			options := BuildOptions{
+				Mode:                BuildModeDevelopment,
+				OutputDirectory:     filepath.Join(root, "dist"),
+				ProjectRootAbsolute: root,
+				Loaders:             DefaultLoaders,
+				Define:              map[string]string{"DEBUG": "true"},
+				OnResult:            func(record BuildRecord) { records = append(records, record) },
+			}
+			config := &ServiceWorkerConfig{Source: tc.source, Filename: tc.filename}
+
+			err := BuildServiceWorker(context.Background(), config, manifest, options)
+			if tc.want == "" {
+				if err == nil {
+					t.Fatal("got no error")
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := os.ReadFile(filepath.Join(root, "dist", filepath.FromSlash(tc.want)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(b), `"./main.js"`) {
+				t.Errorf("got no precache manifest in\n%s", b)
+			}
+			if tc.source == "" {
+				return
+			}
+			if !strings.Contains(string(b), "console.log(manifest, true)") {
+				t.Errorf("got the caller's defines dropped in\n%s", b)
+			}
+			if len(records) != 1 {
+				t.Errorf("got %d build records, want 1", len(records))
+			}
+		})
+	}
+}
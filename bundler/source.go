@@ -0,0 +1,287 @@
+package bundler
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// A Position locates a byte in the source text of a document.
+// Line is 1-based and Column is 0-based, counting bytes like the locations of build errors.
+type Position struct {
+	Offset, Line, Column int
+}
+
+// An elementSource locates the start tag of an element and its attributes in the source text, along with its end
+// tag if it has one. It also keeps the attributes and, for scripts and styles, the text the element was parsed with.
+type elementSource struct {
+	start, end      int // byte range of the start tag
+	close, closeEnd int // byte range of the end tag or -1 if there is none
+	attrs           map[string]attrSource
+	parsedAttrs     []html.Attribute
+	parsedText      string
+}
+
+// An attrSource locates an attribute in the source text. For attributes without a value, valueStart equals valueEnd.
+type attrSource struct {
+	start, end           int // byte range of the whole attribute
+	valueStart, valueEnd int // byte range of the value, excluding quotes
+	quote                byte
+}
+
+// source is the source text of a document.
+type source struct {
+	text  []byte
+	lines []int // byte offsets of the line starts
+}
+
+func newSource(text []byte) *source {
+	lines := []int{0}
+	for i, c := range text {
+		if c == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return &source{text, lines}
+}
+
+// position converts a byte offset into a position.
+func (s *source) position(offset int) Position {
+	line := sort.Search(len(s.lines), func(i int) bool { return s.lines[i] > offset }) - 1
+	return Position{offset, line + 1, offset - s.lines[line]}
+}
+
+// lineText returns the text of the given 1-based line without its line terminator.
+func (s *source) lineText(line int) string {
+	beg, end := s.lines[line-1], len(s.text)
+	if line < len(s.lines) {
+		end = s.lines[line] - 1
+	}
+	return strings.TrimSuffix(string(s.text[beg:end]), "\r")
+}
+
+// elements locates the start tags of all elements below root by matching them to the start tags found by tokenizing
+// the source text. Each element is matched to the first unmatched start tag with the same name and attributes, so
+// elements the parser moves, such as content misplaced in tables, are still located. Implied elements and elements
+// whose start tag the parser changes or merges into another element have no matching start tag; they are returned
+// separately as unlocated, with offsets of -1. End tags are matched to the innermost open start tag of the same name.
+func (s *source) elements(root *html.Node) (located, unlocated map[*html.Node]*elementSource) {
+	type openTag struct {
+		name    string
+		element *elementSource
+	}
+	var open []openTag
+	tags := make(map[string][]*elementSource)
+	z := html.NewTokenizer(bytes.NewReader(s.text))
+	for offset, noscript := 0, false; ; {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		raw := z.Raw()
+		start := offset
+		offset += len(raw)
+
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if noscript {
+				continue
+			}
+			if atom.Lookup(name) == atom.Noscript {
+				// the parser treats the content of <noscript> as text, as it runs with scripting enabled
+				noscript = true
+			}
+			// scan the raw tag before TagAttr unescapes its values in place
+			element := &elementSource{start: start, end: offset, close: -1, closeEnd: -1, attrs: scanAttrs(raw, start)}
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				element.parsedAttrs = append(element.parsedAttrs, html.Attribute{Key: string(key), Val: string(val)})
+			}
+			tags[string(name)] = append(tags[string(name)], element)
+			if tt == html.StartTagToken && !isVoidElement(atom.Lookup(name)) {
+				open = append(open, openTag{string(name), element})
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			if noscript && atom.Lookup(name) != atom.Noscript {
+				continue
+			}
+			noscript = false
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i].name == string(name) {
+					open[i].element.close, open[i].element.closeEnd = start, offset
+					open = open[:i]
+					break
+				}
+			}
+		}
+	}
+
+	located = make(map[*html.Node]*elementSource)
+	unlocated = make(map[*html.Node]*elementSource)
+	var visit func(*html.Node)
+	visit = func(node *html.Node) {
+		if node.Type == html.ElementNode {
+			name := strings.ToLower(node.Data)
+			element := &elementSource{start: -1, end: -1, close: -1, closeEnd: -1}
+			queue := tags[name]
+			for i, tag := range queue {
+				if sameAttrs(tag.parsedAttrs, node.Attr) {
+					element = tag
+					if i == 0 {
+						tags[name] = queue[1:]
+					} else {
+						tags[name] = append(queue[:i:i], queue[i+1:]...)
+					}
+					break
+				}
+			}
+			element.parsedAttrs = append([]html.Attribute(nil), node.Attr...)
+			if isRawTextElement(node) {
+				element.parsedText = textContent(node)
+			}
+			if element.start >= 0 {
+				located[node] = element
+			} else {
+				unlocated[node] = element
+			}
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(root)
+	return located, unlocated
+}
+
+// scanAttrs locates the attributes of the raw start tag found at offset in the source text.
+// Attribute names are lowercased and only the first of several attributes with the same name is kept.
+func scanAttrs(raw []byte, offset int) map[string]attrSource {
+	attrs := make(map[string]attrSource)
+	i := 1
+	for i < len(raw) && !isHTMLSpace(raw[i]) && raw[i] != '>' && raw[i] != '/' {
+		i++ // the tag name
+	}
+	for i < len(raw) {
+		for i < len(raw) && (isHTMLSpace(raw[i]) || raw[i] == '/') {
+			i++
+		}
+		if i >= len(raw) || raw[i] == '>' {
+			break
+		}
+
+		start := i
+		for i++; i < len(raw) && !isHTMLSpace(raw[i]) && raw[i] != '=' && raw[i] != '>' && raw[i] != '/'; i++ {
+		}
+		name := strings.ToLower(string(raw[start:i]))
+		attr := attrSource{start: start, valueStart: i, valueEnd: i}
+
+		j := i
+		for j < len(raw) && isHTMLSpace(raw[j]) {
+			j++
+		}
+		if j < len(raw) && raw[j] == '=' {
+			for j++; j < len(raw) && isHTMLSpace(raw[j]); j++ {
+			}
+			if j < len(raw) && (raw[j] == '"' || raw[j] == '\'') {
+				attr.quote = raw[j]
+				end := bytes.IndexByte(raw[j+1:], raw[j])
+				if end < 0 {
+					end = len(raw) - j - 1
+				}
+				attr.valueStart, attr.valueEnd = j+1, j+1+end
+				i = attr.valueEnd + 1
+			} else {
+				attr.valueStart = j
+				for j < len(raw) && !isHTMLSpace(raw[j]) && raw[j] != '>' {
+					j++
+				}
+				attr.valueEnd = j
+				i = j
+			}
+		}
+		if i > len(raw) {
+			i = len(raw)
+		}
+		attr.end = i
+
+		if _, ok := attrs[name]; !ok {
+			attr.start += offset
+			attr.end += offset
+			attr.valueStart += offset
+			attr.valueEnd += offset
+			attrs[name] = attr
+		}
+	}
+	return attrs
+}
+
+// attrLocation returns the position and length of the value of the node's attribute with the given key or, if the
+// attribute cannot be located, of the node's start tag. It reports false if the node cannot be located at all.
+func (d *Document) attrLocation(node *html.Node, key string) (Position, int, bool) {
+	element, ok := d.elements[node]
+	if !ok {
+		return Position{}, 0, false
+	}
+	if attr, ok := element.attrs[key]; ok {
+		return d.source.position(attr.valueStart), attr.valueEnd - attr.valueStart, true
+	}
+	return d.source.position(element.start), element.end - element.start, true
+}
+
+// sameAttrs reports whether a and b hold the same attributes regardless of their order and the case of their names.
+func sameAttrs(a, b []html.Attribute) bool {
+	return containsAttrs(a, b) && containsAttrs(b, a)
+}
+
+// containsAttrs reports whether every attribute of b is among those of a.
+func containsAttrs(a, b []html.Attribute) bool {
+	for _, y := range b {
+		found := false
+		for _, x := range a {
+			if strings.EqualFold(attrKey(x), attrKey(y)) && x.Val == y.Val {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// isVoidElement reports whether elements of type a have no content and thus no end tag.
+func isVoidElement(a atom.Atom) bool {
+	switch a {
+	case atom.Area, atom.Base, atom.Br, atom.Col, atom.Embed, atom.Hr, atom.Img, atom.Input, atom.Keygen, atom.Link,
+		atom.Meta, atom.Param, atom.Source, atom.Track, atom.Wbr:
+		return true
+	}
+	return false
+}
+
+// isRawTextElement reports whether the content of node is written without escaping.
+func isRawTextElement(node *html.Node) bool {
+	return node.Namespace == "" && (node.DataAtom == atom.Script || node.DataAtom == atom.Style)
+}
+
+// textContent returns the concatenated text of the node's children.
+func textContent(node *html.Node) string {
+	var b strings.Builder
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+		}
+	}
+	return b.String()
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
+}
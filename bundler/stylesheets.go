@@ -0,0 +1,59 @@
+package bundler
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// LinkCSSBundles appends a <link rel="stylesheet"> to <head> for the CSS that esbuild bundled for every script of
+// the document importing CSS, unless the document already links it.
+func (d *Document) LinkCSSBundles(records []BuildRecord, outdir string) error {
+	bundles := make(map[string]string) // maps entry outputs to their CSS bundles
+	for _, record := range records {
+		if record.Output != nil && record.Output.CSSBundle != "" {
+			bundles[record.Output.Entry] = record.Output.CSSBundle
+		}
+	}
+
+	linked := make(map[string]bool)
+	var scripts []*html.Node
+	var visit func(*html.Node)
+	visit = func(node *html.Node) {
+		if node.Type == html.ElementNode && node.Namespace == "" {
+			switch {
+			case node.DataAtom == atom.Script:
+				scripts = append(scripts, node)
+			case node.DataAtom == atom.Link && hasRel(node, "stylesheet"):
+				if path, ok := outputFile(outdir, getAttr(node, "href")); ok {
+					linked[path] = true
+				}
+			}
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(d.root)
+
+	head := d.head()
+	for _, script := range scripts {
+		path, ok := outputFile(outdir, getAttr(script, "src"))
+		css := bundles[path]
+		if !ok || css == "" || linked[css] {
+			continue
+		}
+		linked[css] = true
+
+		href, err := relativeURL(outdir, css)
+		if err != nil {
+			return err
+		}
+		head.AppendChild(&html.Node{
+			Type:     html.ElementNode,
+			Data:     "link",
+			DataAtom: atom.Link,
+			Attr:     []html.Attribute{{Key: "rel", Val: "stylesheet"}, {Key: "href", Val: href}},
+		})
+	}
+	return nil
+}
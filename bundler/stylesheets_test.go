@@ -0,0 +1,72 @@
+package bundler
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDocumentLinkCSSBundles(t *testing.T) {
+	outdir := filepath.FromSlash("/project/dist")
+	records := []BuildRecord{
+		{Output: &BuildResult{Entry: filepath.Join(outdir, "a.js"), CSSBundle: filepath.Join(outdir, "a.css")}},
+		{Output: &BuildResult{Entry: filepath.Join(outdir, "b.js"), CSSBundle: filepath.Join(outdir, "b.css")}},
+		{Output: &BuildResult{Entry: filepath.Join(outdir, "c.js")}},
+		{},
+	}
+
+	tcs := []struct {
+		name     string
+		src      string
+		fragment bool
+		want     string
+	}{
+		{
+			"scripts importing CSS",
+			`<head><script type="module" src="./a.js"></script></head><body><script src="b.js"></script></body>`,
+			false,
+			`<html><head><script type="module" src="./a.js"></script><link rel="stylesheet" href="./a.css"/>` +
+				`<link rel="stylesheet" href="./b.css"/></head><body><script src="b.js"></script></body></html>`,
+		},
+		{
+			"already linked",
+			`<head><link rel="stylesheet" href="./a.css"/><script type="module" src="./a.js"></script></head>`,
+			false,
+			`<html><head><link rel="stylesheet" href="./a.css"/><script type="module" src="./a.js"></script></head>` +
+				`<body></body></html>`,
+		},
+		{
+			"no CSS",
+			`<head><script src="./c.js"></script><script src="https://cdn/a.js"></script><script>a()</script></head>`,
+			false,
+			`<html><head><script src="./c.js"></script><script src="https://cdn/a.js"></script><script>a()</script>` +
+				`</head><body></body></html>`,
+		},
+		{
+			"fragment",
+			`<p>p</p><script type="module" src="./a.js"></script><script type="module" src="./a.js?v=2"></script>`,
+			true,
+			`<p>p</p><script type="module" src="./a.js"></script><script type="module" src="./a.js?v=2"></script>` +
+				`<link rel="stylesheet" href="./a.css"/>`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := ParseDocument(strings.NewReader(tc.src), ParseOptions{Fragment: tc.fragment})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := doc.LinkCSSBundles(records, outdir); err != nil {
+				t.Fatal(err)
+			}
+			var b strings.Builder
+			if _, err := doc.WriteTo(&b); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tc.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package bundler
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// templateBlock matches the actions, expressions and tags of Go, Jinja and similar templates.
+var templateBlock = regexp.MustCompile(`(?s)\{\{.*?\}\}|\{%.*?%\}`)
+
+// templates replaces the template blocks of a text by placeholders the HTML parser treats like any other name or
+// text, and restores them in the rendered output.
+type templates struct {
+	blocks      map[string]string // maps placeholders to blocks
+	placeholder *regexp.Regexp
+}
+
+// newTemplates replaces all template blocks in text. The placeholders are padded to the length of the blocks where
+// possible, so positions in the text remain valid. If text contains no template blocks, newTemplates returns nil.
+func newTemplates(text []byte) (*templates, []byte) {
+	prefix := "cvbtpl"
+	for i := 0; bytes.Contains(text, []byte(prefix)); i++ {
+		prefix = "cvbtpl" + strconv.Itoa(i) + "x"
+	}
+
+	t := &templates{blocks: make(map[string]string)}
+	var placeholders []string
+	text = templateBlock.ReplaceAllFunc(text, func(block []byte) []byte {
+		placeholder := prefix + strconv.Itoa(len(placeholders)) + "_"
+		if n := len(block) - len(placeholder); n > 0 {
+			placeholder += strings.Repeat("_", n)
+		}
+		placeholders = append(placeholders, regexp.QuoteMeta(placeholder))
+		t.blocks[placeholder] = string(block)
+		return []byte(placeholder)
+	})
+	if len(placeholders) == 0 {
+		return nil, text
+	}
+	t.placeholder = regexp.MustCompile(`(` + strings.Join(placeholders, "|") + `)(?:="")?`)
+	return t, text
+}
+
+// contains reports whether s contains a template block.
+func (t *templates) contains(s string) bool {
+	return t.placeholder.MatchString(s)
+}
+
+// restore replaces the placeholders in b by the template blocks. Placeholders which the parser took for attribute
+// names are rendered with an empty value, which is dropped.
+func (t *templates) restore(b []byte) []byte {
+	return t.placeholder.ReplaceAllFunc(b, func(match []byte) []byte {
+		return []byte(t.blocks[string(t.placeholder.FindSubmatch(match)[1])])
+	})
+}
@@ -0,0 +1,244 @@
+package bundler
+
+import (
+	"bytes"
+	"strings"
+)
+
+type tokenKind uint8
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdentifier
+	tokenPunctuator
+	tokenNumber
+	tokenString
+	tokenTemplate
+	tokenRegExp
+)
+
+// A token is a lexical token of JavaScript source text.
+// Punctuators are split into single characters, which is enough to recognize the expressions scanned for.
+type token struct {
+	kind       tokenKind
+	start, end int    // byte range of the token in the source
+	value      string // the identifier, punctuator or (unescaped) content of a string or template literal
+	dynamic    bool   // whether a template literal contains substitutions, each of which is replaced by '*' in value
+}
+
+// A tokenizer splits JavaScript source text into tokens, skipping whitespace and comments.
+type tokenizer struct {
+	src  []byte
+	pos  int
+	prev token
+}
+
+// keywordsBeforeExpression are the keywords after which a '/' starts a regular expression rather than a division.
+var keywordsBeforeExpression = map[string]bool{
+	"await": true, "case": true, "delete": true, "do": true, "else": true, "in": true, "instanceof": true,
+	"new": true, "of": true, "return": true, "throw": true, "typeof": true, "void": true, "yield": true,
+}
+
+func newTokenizer(src []byte) *tokenizer {
+	t := &tokenizer{src: src}
+	if len(src) > 1 && src[0] == '#' && src[1] == '!' {
+		t.skipLine()
+	}
+	return t
+}
+
+// tokenize returns all tokens of src, excluding the final EOF token.
+func tokenize(src []byte) []token {
+	var tokens []token
+	t := newTokenizer(src)
+	for tok := t.next(); tok.kind != tokenEOF; tok = t.next() {
+		tokens = append(tokens, tok)
+	}
+	return tokens
+}
+
+func (t *tokenizer) next() token {
+	t.skipSpaceAndComments()
+	if t.pos >= len(t.src) {
+		return token{kind: tokenEOF, start: t.pos, end: t.pos}
+	}
+
+	start := t.pos
+	var tok token
+	switch c := t.src[t.pos]; {
+	case isIdentifierByte(c) && !isDigit(c):
+		for t.pos < len(t.src) && isIdentifierByte(t.src[t.pos]) {
+			t.pos++
+		}
+		tok = token{kind: tokenIdentifier}
+	case isDigit(c) || c == '.' && t.pos+1 < len(t.src) && isDigit(t.src[t.pos+1]):
+		for t.pos < len(t.src) && (isIdentifierByte(t.src[t.pos]) || t.src[t.pos] == '.') {
+			t.pos++
+		}
+		tok = token{kind: tokenNumber}
+	case c == '\'' || c == '"':
+		tok = token{kind: tokenString, value: t.scanString(c)}
+	case c == '`':
+		value, dynamic := t.scanTemplate()
+		tok = token{kind: tokenTemplate, value: value, dynamic: dynamic}
+	case c == '/' && t.regExpAllowed():
+		t.scanRegExp()
+		tok = token{kind: tokenRegExp}
+	default:
+		t.pos++
+		tok = token{kind: tokenPunctuator}
+	}
+
+	tok.start, tok.end = start, t.pos
+	if tok.kind == tokenIdentifier || tok.kind == tokenPunctuator {
+		tok.value = string(t.src[start:t.pos])
+	}
+	t.prev = tok
+	return tok
+}
+
+// regExpAllowed reports whether a '/' at the current position starts a regular expression, judging by the previous token.
+func (t *tokenizer) regExpAllowed() bool {
+	switch t.prev.kind {
+	case tokenEOF:
+		return true
+	case tokenPunctuator:
+		return t.prev.value != ")" && t.prev.value != "]"
+	case tokenIdentifier:
+		return keywordsBeforeExpression[t.prev.value]
+	default:
+		return false
+	}
+}
+
+func (t *tokenizer) skipSpaceAndComments() {
+	for t.pos < len(t.src) {
+		switch c := t.src[t.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			t.pos++
+		case c == '/' && t.pos+1 < len(t.src) && t.src[t.pos+1] == '/':
+			t.skipLine()
+		case c == '/' && t.pos+1 < len(t.src) && t.src[t.pos+1] == '*':
+			if end := bytes.Index(t.src[t.pos+2:], []byte("*/")); end < 0 {
+				t.pos = len(t.src)
+			} else {
+				t.pos += end + 4
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (t *tokenizer) skipLine() {
+	for t.pos < len(t.src) && t.src[t.pos] != '\n' {
+		t.pos++
+	}
+}
+
+// scanString scans a string literal delimited by quote and returns its unescaped content.
+func (t *tokenizer) scanString(quote byte) string {
+	var b strings.Builder
+	for t.pos++; t.pos < len(t.src); t.pos++ {
+		switch c := t.src[t.pos]; c {
+		case quote:
+			t.pos++
+			return b.String()
+		case '\\':
+			t.pos++
+			t.unescape(&b)
+		case '\n':
+			return b.String() // unterminated
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// scanTemplate scans a template literal and returns its unescaped content with each substitution replaced by '*'.
+func (t *tokenizer) scanTemplate() (string, bool) {
+	var b strings.Builder
+	dynamic := false
+	for t.pos++; t.pos < len(t.src); t.pos++ {
+		switch c := t.src[t.pos]; {
+		case c == '`':
+			t.pos++
+			return b.String(), dynamic
+		case c == '\\':
+			t.pos++
+			t.unescape(&b)
+		case c == '$' && t.pos+1 < len(t.src) && t.src[t.pos+1] == '{':
+			t.pos += 2
+			t.prev = token{kind: tokenPunctuator, value: "{"}
+			for depth := 0; ; {
+				tok := t.next()
+				if tok.kind == tokenEOF {
+					return b.String(), true
+				} else if tok.value == "{" {
+					depth++
+				} else if tok.value == "}" {
+					if depth == 0 {
+						break
+					}
+					depth--
+				}
+			}
+			t.pos--
+			b.WriteByte('*')
+			dynamic = true
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), dynamic
+}
+
+// scanRegExp scans a regular expression literal including its flags.
+func (t *tokenizer) scanRegExp() {
+	class := false
+	for t.pos++; t.pos < len(t.src); t.pos++ {
+		switch t.src[t.pos] {
+		case '\\':
+			t.pos++
+		case '[':
+			class = true
+		case ']':
+			class = false
+		case '\n':
+			return // unterminated
+		case '/':
+			if !class {
+				for t.pos++; t.pos < len(t.src) && isIdentifierByte(t.src[t.pos]); t.pos++ {
+				}
+				return
+			}
+		}
+	}
+}
+
+// unescape writes the character escaped by the backslash preceding the current position to b.
+func (t *tokenizer) unescape(b *strings.Builder) {
+	if t.pos >= len(t.src) {
+		return
+	}
+	switch c := t.src[t.pos]; c {
+	case 'n':
+		b.WriteByte('\n')
+	case 't':
+		b.WriteByte('\t')
+	case 'r':
+		b.WriteByte('\r')
+	case '\r', '\n': // line continuation
+	default:
+		b.WriteByte(c)
+	}
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isIdentifierByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || isDigit(c) || c == '_' || c == '$' || c == '#' || c >= 0x80
+}
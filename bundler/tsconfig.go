@@ -0,0 +1,133 @@
+package bundler
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// TSConfig holds the parts of a tsconfig.json or jsconfig.json file that cvbuild honors.
+type TSConfig struct {
+	CompilerOptions struct {
+		BaseURL string              `json:"baseUrl"`
+		Paths   map[string][]string `json:"paths"`
+
+		JSX                string `json:"jsx"`
+		JSXFactory         string `json:"jsxFactory"`
+		JSXFragmentFactory string `json:"jsxFragmentFactory"`
+		JSXImportSource    string `json:"jsxImportSource"`
+	} `json:"compilerOptions"`
+
+	dir string
+}
+
+// FindTSConfig returns the path of the tsconfig.json or, failing that, the jsconfig.json file in dir.
+func FindTSConfig(dir string) (string, bool) {
+	for _, name := range []string{"tsconfig.json", "jsconfig.json"} {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// ReadTSConfig parses the tsconfig.json or jsconfig.json file at path.
+// Comments and trailing commas are permitted, as they are by the TypeScript compiler.
+func ReadTSConfig(path string) (*TSConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config TSConfig
+	if err := json.Unmarshal(stripJSONComments(b), &config); err != nil {
+		return nil, err
+	}
+	config.dir = filepath.Dir(path)
+	return &config, nil
+}
+
+// PathAliases converts compilerOptions.paths to path aliases.
+// Targets are relative to compilerOptions.baseUrl or, if it is not set, to the directory containing the file.
+func (c *TSConfig) PathAliases() []PathAlias {
+	base := filepath.Join(c.dir, c.CompilerOptions.BaseURL)
+	aliases := make([]PathAlias, 0, len(c.CompilerOptions.Paths))
+	for pattern, targets := range c.CompilerOptions.Paths {
+		alias := PathAlias{Pattern: pattern, Targets: make([]string, len(targets))}
+		for i, target := range targets {
+			alias.Targets[i] = filepath.Join(base, target)
+		}
+		aliases = append(aliases, alias)
+	}
+	return aliases
+}
+
+// JSXOptions converts the compilerOptions concerning JSX to their esbuild equivalents.
+func (c *TSConfig) JSXOptions() JSXOptions {
+	options := JSXOptions{
+		Factory:      c.CompilerOptions.JSXFactory,
+		Fragment:     c.CompilerOptions.JSXFragmentFactory,
+		ImportSource: c.CompilerOptions.JSXImportSource,
+	}
+	switch strings.ToLower(c.CompilerOptions.JSX) {
+	case "preserve", "react-native":
+		options.Mode = api.JSXPreserve
+	case "react-jsx":
+		options.Mode = api.JSXAutomatic
+	case "react-jsxdev":
+		options.Mode = api.JSXAutomatic
+		options.Dev = true
+	}
+	return options
+}
+
+// stripJSONComments removes comments and trailing commas from the JSON-with-comments text in b.
+func stripJSONComments(b []byte) []byte {
+	r := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		switch c := b[i]; {
+		case c == '"':
+			j := i + 1
+			for ; j < len(b) && b[j] != '"'; j++ {
+				if b[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(b) {
+				j = len(b) - 1
+			}
+			r = append(r, b[i:j+1]...)
+			i = j
+		case c == '/' && i+1 < len(b) && b[i+1] == '/':
+			for i < len(b) && b[i] != '\n' {
+				i++
+			}
+			i--
+		case c == '/' && i+1 < len(b) && b[i+1] == '*':
+			i += 2
+			for i+1 < len(b) && !(b[i] == '*' && b[i+1] == '/') {
+				i++
+			}
+			i++
+		case c == ']' || c == '}':
+			j := len(r) - 1
+			for j >= 0 && isJSONSpace(r[j]) {
+				j--
+			}
+			if j >= 0 && r[j] == ',' {
+				r = append(r[:j], r[j+1:]...)
+			}
+			r = append(r, c)
+		default:
+			r = append(r, c)
+		}
+	}
+	return r
+}
+
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
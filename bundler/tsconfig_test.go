@@ -0,0 +1,64 @@
+package bundler
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestTSConfigPathAliases(t *testing.T) {
+	tcs := []struct {
+		name    string
+		baseURL string
+		base    string // the directory targets are relative to, relative to the project root
+	}{
+		{"without baseUrl", "", "config"},
+		{"with baseUrl", `"baseUrl": "../web",`, "web"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			root := writeFiles(t, t.TempDir(), map[string]string{
+				"config/tsconfig.json": `{
+					// comments and trailing commas are permitted
+					"compilerOptions": {
+						` + tc.baseURL + `
+						"paths": {"@/*": ["src/*"], "lib": ["vendor/lib.js", "lib/index.js",]},
+					},
+				}`,
+			})
+			config, err := ReadTSConfig(filepath.Join(root, "config", "tsconfig.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := config.PathAliases()
+			sort.Slice(got, func(i, j int) bool { return got[i].Pattern < got[j].Pattern })
+			base := filepath.Join(root, tc.base)
+			want := []PathAlias{
+				{"@/*", []string{filepath.Join(base, "src", "*")}},
+				{"lib", []string{filepath.Join(base, "vendor", "lib.js"), filepath.Join(base, "lib", "index.js")}},
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestStripJSONComments(t *testing.T) {
+	in := `{
+		// line comment
+		"a": "b // not a comment", /* block */
+		"c": [1, 2,],
+	}`
+	want := `{
+		
+		"a": "b // not a comment", 
+		"c": [1, 2]
+	}`
+	if got := string(stripJSONComments([]byte(in))); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+
+	"github.com/dlw93/cvbuild/bundler"
+)
+
+type LogLevel uint
+
+const (
+	LogLevelSilent LogLevel = iota
+	LogLevelError
+	LogLevelWarning
+	LogLevelInfo
+)
+
+var logLevelNames = []string{"silent", "error", "warning", "info"}
+
+type LogFormat uint
+
+const (
+	LogFormatText LogFormat = iota
+	LogFormatJSON
+)
+
+var logFormatNames = []string{"text", "json"}
+
+// A Logger renders errors and warnings with code frames, notes and suggestions, colorized if written to a terminal.
+// In JSON format, it collects them instead and writes a BuildSummary when it is closed.
+type Logger struct {
+	w                io.Writer
+	level            LogLevel
+	color            bool
+	warningsAsErrors bool
+	start            time.Time
+
+	errorMsgs, warningMsgs []api.Message
+
+	json    io.Writer
+	input   string
+	records func() []bundler.BuildRecord
+}
+
+// NewLogger returns a logger writing messages up to the given level to f.
+// If warningsAsErrors is set, warnings are reported as errors.
+func NewLogger(f *os.File, level LogLevel, warningsAsErrors bool) *Logger {
+	return &Logger{w: f, level: level, color: isTerminal(f), warningsAsErrors: warningsAsErrors, start: time.Now()}
+}
+
+// EnableJSON switches the logger to the JSON format. On Close, the summary of the build of input, including the
+// builds returned by records, is written to w.
+func (l *Logger) EnableJSON(w io.Writer, input string, records func() []bundler.BuildRecord) {
+	l.json, l.input, l.records = w, input, records
+}
+
+// Error reports err, splitting joined errors into separate messages.
+func (l *Logger) Error(err error) {
+	msgs := messages(err)
+	l.errorMsgs = append(l.errorMsgs, msgs...)
+	l.print(msgs, api.ErrorMessage, LogLevelError)
+}
+
+// Errorf reports an error formatted according to the format specifier.
+func (l *Logger) Errorf(format string, a ...any) {
+	l.Error(fmt.Errorf(format, a...))
+}
+
+// Fatal reports err and closes the logger, exiting with a non-zero exit code.
+func (l *Logger) Fatal(err error) {
+	l.Error(err)
+	l.Close()
+}
+
+// Fatalf reports an error formatted according to the format specifier and exits with a non-zero exit code.
+func (l *Logger) Fatalf(format string, a ...any) {
+	l.Fatal(fmt.Errorf(format, a...))
+}
+
+// Warnings reports the warnings, or errors if warnings are treated as such.
+func (l *Logger) Warnings(msgs []api.Message) {
+	if l.warningsAsErrors {
+		l.errorMsgs = append(l.errorMsgs, msgs...)
+		l.print(msgs, api.ErrorMessage, LogLevelError)
+	} else {
+		l.warningMsgs = append(l.warningMsgs, msgs...)
+		l.print(msgs, api.WarningMessage, LogLevelWarning)
+	}
+}
+
+// Infof reports an informational message formatted according to the format specifier.
+func (l *Logger) Infof(format string, a ...any) {
+	if l.level >= LogLevelInfo && l.json == nil {
+		fmt.Fprintf(l.w, format+"\n", a...)
+	}
+}
+
+// Failed reports whether any errors have been reported.
+func (l *Logger) Failed() bool {
+	return len(l.errorMsgs) > 0
+}
+
+// Close writes the summary in JSON format and exits with a non-zero exit code if any errors have been reported.
+func (l *Logger) Close() {
+	if l.json != nil {
+		if err := l.writeSummary(l.json, l.input, l.records()); err != nil {
+			fmt.Fprintln(l.w, err)
+			os.Exit(1)
+		}
+	}
+	if l.Failed() {
+		os.Exit(1)
+	}
+}
+
+func (l *Logger) print(msgs []api.Message, kind api.MessageKind, level LogLevel) {
+	if l.level < level || len(msgs) == 0 || l.json != nil {
+		return
+	}
+	formatted := api.FormatMessages(msgs, api.FormatMessagesOptions{Kind: kind, Color: l.color})
+	fmt.Fprint(l.w, strings.Join(formatted, ""))
+}
+
+// messages converts err to esbuild messages, keeping the locations of build errors.
+func messages(err error) []api.Message {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case bundler.BuildError:
+		return []api.Message{api.Message(e)}
+	case *bundler.DependencyError:
+		msgs := messages(e.Err)
+		note := api.Note{Text: fmt.Sprintf("The dependency was referenced by <%s %s=%q>", e.Tag, e.Attr, e.Value)}
+		if e.Line > 0 {
+			note.Location = &api.Location{File: e.File, Line: e.Line, Column: e.Column, Length: e.Length, LineText: e.LineText}
+		} else if e.File != "" {
+			note.Text += " in " + e.File
+		}
+		for i := range msgs {
+			if msgs[i].Location == nil && note.Location != nil {
+				// the error concerns the reference itself, e.g. because it cannot be resolved
+				msgs[i].Location = note.Location
+			} else {
+				msgs[i].Notes = append(msgs[i].Notes, note)
+			}
+		}
+		return msgs
+	case interface{ Unwrap() []error }:
+		var msgs []api.Message
+		for _, err := range e.Unwrap() {
+			msgs = append(msgs, messages(err)...)
+		}
+		return msgs
+	default:
+		return []api.Message{{Text: err.Error()}}
+	}
+}
+
+func (l LogLevel) String() string {
+	if int(l) < len(logLevelNames) {
+		return logLevelNames[l]
+	}
+	return fmt.Sprintf("LogLevel(%d)", uint(l))
+}
+
+// Set parses the name of a log level, implementing flag.Value.
+func (l *LogLevel) Set(s string) error {
+	for i, name := range logLevelNames {
+		if name == s {
+			*l = LogLevel(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown log level %q, want one of %s", s, strings.Join(logLevelNames, ", "))
+}
+
+func (f LogFormat) String() string {
+	if int(f) < len(logFormatNames) {
+		return logFormatNames[f]
+	}
+	return fmt.Sprintf("LogFormat(%d)", uint(f))
+}
+
+// Set parses the name of a log format, implementing flag.Value.
+func (f *LogFormat) Set(s string) error {
+	for i, name := range logFormatNames {
+		if name == s {
+			*f = LogFormat(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown format %q, want one of %s", s, strings.Join(logFormatNames, ", "))
+}
+
+func isTerminal(f *os.File) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+
+	"github.com/dlw93/cvbuild/bundler"
+)
+
+func TestMessages(t *testing.T) {
+	buildErr := bundler.BuildError{Text: "syntax error", Location: &api.Location{File: "src/main.js", Line: 3, Column: 4}}
+	depErr := &bundler.DependencyError{
+		File: "index.html", Tag: "script", Attr: "src", Value: "/src/main.js",
+		Line: 2, Column: 13, Length: 12, LineText: `<script src="/src/main.js"></script>`,
+	}
+	refLocation := &api.Location{File: "index.html", Line: 2, Column: 13, Length: 12, LineText: depErr.LineText}
+	refNote := `The dependency was referenced by <script src="/src/main.js">`
+	with := func(e *bundler.DependencyError, err error) *bundler.DependencyError {
+		e2 := *e
+		e2.Err = err
+		return &e2
+	}
+
+	tcs := []struct {
+		name string
+		err  error
+		want []api.Message
+	}{
+		{"nil", nil, nil},
+		{"error", errors.New("failed"), []api.Message{{Text: "failed"}}},
+		{"wrapped error", fmt.Errorf("outer: %w", buildErr), []api.Message{{Text: "outer: " + buildErr.Error()}}},
+		{"build error", buildErr, []api.Message{api.Message(buildErr)}},
+		{
+			// the error concerns the reference itself, so the message is located there
+			"dependency error without location",
+			with(depErr, errors.New("could not resolve")),
+			[]api.Message{{Text: "could not resolve", Location: refLocation}},
+		},
+		{
+			// the error is located in the dependency, so the reference is a note
+			"dependency error with location",
+			with(depErr, buildErr),
+			[]api.Message{{
+				Text:     buildErr.Text,
+				Location: buildErr.Location,
+				Notes:    []api.Note{{Text: refNote, Location: refLocation}},
+			}},
+		},
+		{
+			"dependency error of unknown location",
+			&bundler.DependencyError{File: "index.html", Tag: "img", Attr: "src", Value: "a.png", Err: errors.New("failed")},
+			[]api.Message{{Text: "failed", Notes: []api.Note{{Text: `The dependency was referenced by <img src="a.png"> in index.html`}}}},
+		},
+		{
+			"joined errors",
+			errors.Join(errors.New("a"), errors.Join(buildErr, errors.New("b"))),
+			[]api.Message{{Text: "a"}, api.Message(buildErr), {Text: "b"}},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := messages(tc.err); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoggerWarnings(t *testing.T) {
+	warnings := []api.Message{{Text: "unused import"}}
+
+	tcs := []struct {
+		name             string
+		warningsAsErrors bool
+		level            LogLevel
+		want             string
+	}{
+		{"warnings", false, LogLevelWarning, "[WARNING] unused import"},
+		{"warnings as errors", true, LogLevelWarning, "[ERROR] unused import"},
+		{"silent", false, LogLevelSilent, ""},
+		{"errors only", false, LogLevelError, ""},
+		{"errors only, warnings as errors", true, LogLevelError, "[ERROR] unused import"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			var b strings.Builder
+			l := &Logger{w: &b, level: tc.level, warningsAsErrors: tc.warningsAsErrors}
+			l.Warnings(warnings)
+
+			if got := l.Failed(); got != tc.warningsAsErrors {
+				t.Errorf("got failed %t, want %t", got, tc.warningsAsErrors)
+			}
+			if got := b.String(); tc.want == "" && got != "" || !strings.Contains(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
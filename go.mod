@@ -2,10 +2,11 @@ module github.com/dlw93/cvbuild
 
 go 1.20
 
-require github.com/evanw/esbuild v0.17.11
-
 require (
+	github.com/andybalholm/brotli v1.0.5
+	github.com/evanw/esbuild v0.17.11
 	golang.org/x/exp v0.0.0-20230304125523-9ff063c70017
 	golang.org/x/net v0.8.0
-	golang.org/x/sys v0.6.0 // indirect
 )
+
+require golang.org/x/sys v0.6.0 // indirect
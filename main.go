@@ -1,75 +1,164 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
-	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"time"
+
+	"github.com/dlw93/cvbuild/bundler"
 )
 
 var args struct {
+	Mode                bundler.BuildMode
 	InputFile           string
 	OutputDirectory     string
 	ProjectRootAbsolute string
+	ConfigFile          string
+	Manifest            bool
+	Metafile            string
+	Report              bool
+	Analyze             bool
+	LogLevel            LogLevel
+	WarningsAsErrors    bool
+	Format              LogFormat
+	PreserveFormatting  bool
+	Fragment            bool
+	Templates           bool
+	Integrity           bool
+	CrossOrigin         string
+	CSP                 bundler.CSPMode
+	Preload             bool
+	Timeout             time.Duration
 }
 
+var logger *Logger
+
 func init() {
+	args.LogLevel = LogLevelWarning
+
 	cwd, err := os.Getwd()
 	if err != nil {
 		panic(err)
 	}
+	flag.Var(&args.Mode, "mode", "the build mode (production or development); production builds minify all outputs")
 	flag.StringVar(&args.InputFile, "input-file", "./index.html", "input file")
 	flag.StringVar(&args.OutputDirectory, "output-directory", "./dist", "output directory")
 	flag.StringVar(&args.ProjectRootAbsolute, "project-root", cwd, "absolute path to the project root directory")
+	flag.StringVar(&args.ConfigFile, "config", "", "path to a JSON configuration file")
+	flag.BoolVar(&args.Manifest, "manifest", false, "write a manifest.json mapping source paths to their outputs into the output directory")
+	flag.StringVar(&args.Metafile, "metafile", "", "path to write the esbuild metafile of all builds to")
+	flag.BoolVar(&args.Report, "report", false, "print the raw and compressed sizes of all outputs")
+	flag.BoolVar(&args.Analyze, "analyze", false, "write a report.html breaking down the outputs by module into the output directory")
+	flag.DurationVar(&args.Timeout, "timeout", 0, "cancel the build if it takes longer than this, e.g. 2m (0 for no timeout)")
+	flag.Var(&args.LogLevel, "log-level", "the level of messages to print (silent, error, warning or info)")
+	flag.BoolVar(&args.WarningsAsErrors, "warnings-as-errors", false, "treat warnings as errors")
+	flag.Var(&args.Format, "format", "the format of the build output (text or json)")
+	flag.BoolVar(&args.Fragment, "fragment", false, "treat the input file as an HTML fragment instead of a complete document")
+	flag.BoolVar(&args.Templates, "template", false, "leave template blocks like {{ ... }} and {% ... %} in the input file untouched")
+	flag.BoolVar(&args.Integrity, "integrity", false, "add subresource integrity attributes to the scripts and stylesheets of the input file")
+	flag.StringVar(&args.CrossOrigin, "crossorigin", "anonymous", "the crossorigin attribute to add along with integrity attributes, if not empty")
+	flag.BoolVar(&args.Preload, "preload", false, "add preload hints for imported chunks and externals, fonts and critical images to the input file")
+	flag.Var(&args.CSP, "csp", "emit a Content Security Policy for the input file (off, meta or headers to write a _headers file)")
+	flag.BoolVar(&args.PreserveFormatting, "preserve-formatting", false, "rewrite references in the original markup of the input file instead of re-rendering it")
 }
 
 func main() {
 	flag.Parse()
+	logger = NewLogger(os.Stderr, args.LogLevel, args.WarningsAsErrors)
+	recorder := &bundler.BuildRecorder{}
+	if args.Format == LogFormatJSON {
+		logger.EnableJSON(os.Stdout, args.InputFile, recorder.All)
+	}
 
 	if info, err := os.Stat(args.InputFile); err != nil || info.Mode().IsDir() {
-		log.Fatalf("entry point %s does not exist", args.InputFile)
+		logger.Fatalf("entry point %s does not exist", args.InputFile)
 	} else if info.IsDir() {
-		log.Fatalf("entry point %s is a directory", args.InputFile)
+		logger.Fatalf("entry point %s is a directory", args.InputFile)
 	}
 
 	if info, err := os.Stat(args.ProjectRootAbsolute); err != nil {
-		log.Fatalf("project root %s does not exist", args.ProjectRootAbsolute)
+		logger.Fatalf("project root %s does not exist", args.ProjectRootAbsolute)
 	} else if !info.IsDir() {
-		log.Fatalf("project root %s is not a directory", args.ProjectRootAbsolute)
+		logger.Fatalf("project root %s is not a directory", args.ProjectRootAbsolute)
 	}
 
-	file, err := os.Open(args.InputFile)
-	if err != nil {
-		log.Fatalf("failed to open entry point %s: %s", args.InputFile, err)
+	if outdir, err := filepath.Abs(args.OutputDirectory); err != nil {
+		logger.Fatalf("invalid output directory %s: %s", args.OutputDirectory, err)
+	} else {
+		args.OutputDirectory = outdir
 	}
-	defer file.Close()
 
-	doc, err := NewDocument(file)
+	config := &bundler.Config{}
+	if args.ConfigFile != "" {
+		if c, err := bundler.LoadConfig(args.ConfigFile); err != nil {
+			logger.Fatalf("failed to load config %s: %s", args.ConfigFile, err)
+		} else {
+			config = c
+		}
+	}
+
+	options, err := bundler.NewDocumentOptions(config, args.ProjectRootAbsolute, args.InputFile)
 	if err != nil {
-		log.Fatalf("failed to parse %s: %s", args.InputFile, err)
+		logger.Fatalf("invalid configuration: %s", err)
 	}
 
-	err = doc.Walk(func(path string) (string, error) {
-		options := BuildOptions{args.OutputDirectory, args.ProjectRootAbsolute}
-		if result, err := Build(path, options); err != nil {
-			return "", err
-		} else {
-			return result, nil
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if args.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, args.Timeout)
+		defer cancel()
+	}
+
+	logged := 0
+	logWarnings := func() {
+		records := recorder.All()
+		for _, record := range records[logged:] {
+			logger.Warnings(record.Result.Warnings)
 		}
-	})
-	if err != nil {
-		log.Fatalf("failed to process dependency in %s: %s", args.InputFile, err)
+		logged = len(records)
 	}
 
-	outpath := filepath.Join(args.OutputDirectory, filepath.Base(args.InputFile))
-	outfile, err := os.Create(outpath)
-	if err != nil {
-		log.Fatalf("failed to create %s: %s", outpath, err)
+	options.Mode = args.Mode
+	options.OutputDirectory = args.OutputDirectory
+	options.OnResult = recorder.Record
+	options.Parse = bundler.ParseOptions{Fragment: args.Fragment, Templates: args.Templates}
+	options.Preload = args.Preload
+	options.Integrity = args.Integrity
+	options.CrossOrigin = args.CrossOrigin
+	options.CSP = args.CSP
+	options.PreserveFormatting = args.PreserveFormatting
+	options.Report = args.Report
+	options.Manifest = args.Manifest
+	options.Analyze = args.Analyze
+	options.Metafile = args.Metafile
+	options.OnWalk = func(err error) error {
+		logWarnings()
+		if err == nil && logger.Failed() {
+			logger.Close() // warnings treated as errors
+		}
+		return err
 	}
-	defer outfile.Close()
 
-	err = doc.WriteTo(outfile)
-	if err != nil {
-		log.Fatalf("failed to write to %s: %s", outpath, err)
+	result, err := bundler.BuildDocument(ctx, options)
+	logWarnings()
+	if args.Report && args.Format == LogFormatText && result.Sizes != nil {
+		if err := bundler.WriteSizeReport(os.Stdout, result.Sizes); err != nil {
+			logger.Fatalf("failed to write size report: %s", err)
+		}
 	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		logger.Fatalf("build timed out after %s", args.Timeout)
+	} else if errors.Is(err, context.Canceled) {
+		logger.Fatalf("build interrupted")
+	} else if err != nil {
+		logger.Fatal(err)
+	}
+
+	logger.Infof("wrote %d files to %s", len(result.Files), args.OutputDirectory)
+	logger.Close()
 }
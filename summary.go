@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+
+	"github.com/dlw93/cvbuild/bundler"
+)
+
+// A BuildSummary is the machine-readable description of a build written by the logger in JSON format.
+type BuildSummary struct {
+	Input      string            `json:"input"`
+	Duration   float64           `json:"durationMs"`
+	Builds     []BuildSummaryRun `json:"builds"`
+	Warnings   []SummaryMessage  `json:"warnings"`
+	Errors     []SummaryMessage  `json:"errors"`
+	Successful bool              `json:"successful"`
+}
+
+type BuildSummaryRun struct {
+	Input    string           `json:"input"`
+	Outputs  []SummaryOutput  `json:"outputs"`
+	Duration float64          `json:"durationMs"`
+	Warnings []SummaryMessage `json:"warnings"`
+	Errors   []SummaryMessage `json:"errors"`
+}
+
+type SummaryOutput struct {
+	Path  string `json:"path"`
+	Bytes int    `json:"bytes"`
+}
+
+type SummaryMessage struct {
+	Text     string           `json:"text"`
+	Plugin   string           `json:"plugin,omitempty"`
+	Location *SummaryLocation `json:"location,omitempty"`
+	Notes    []SummaryMessage `json:"notes,omitempty"`
+}
+
+// A SummaryLocation has the fields of a BuildError's location; Line is 1-based and Column is 0-based, in bytes.
+type SummaryLocation struct {
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Column     int    `json:"column"`
+	Length     int    `json:"length"`
+	LineText   string `json:"lineText"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// writeSummary writes the summary of the logged messages and the given builds as JSON to w.
+func (l *Logger) writeSummary(w io.Writer, input string, records []bundler.BuildRecord) error {
+	summary := BuildSummary{
+		Input:      input,
+		Duration:   milliseconds(time.Since(l.start)),
+		Builds:     make([]BuildSummaryRun, len(records)),
+		Warnings:   summaryMessages(l.warningMsgs),
+		Errors:     summaryMessages(l.errorMsgs),
+		Successful: !l.Failed(),
+	}
+	for i, record := range records {
+		run := BuildSummaryRun{
+			Input:    record.Input,
+			Outputs:  make([]SummaryOutput, len(record.Result.OutputFiles)),
+			Duration: milliseconds(record.Duration),
+			Warnings: summaryMessages(record.Result.Warnings),
+			Errors:   summaryMessages(record.Result.Errors),
+		}
+		for j, file := range record.Result.OutputFiles {
+			run.Outputs[j] = SummaryOutput{file.Path, len(file.Contents)}
+		}
+		summary.Builds[i] = run
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(summary)
+}
+
+func summaryMessages(msgs []api.Message) []SummaryMessage {
+	r := make([]SummaryMessage, len(msgs))
+	for i, msg := range msgs {
+		r[i] = SummaryMessage{Text: msg.Text, Plugin: msg.PluginName, Location: summaryLocation(msg.Location)}
+		for _, note := range msg.Notes {
+			r[i].Notes = append(r[i].Notes, SummaryMessage{Text: note.Text, Location: summaryLocation(note.Location)})
+		}
+	}
+	return r
+}
+
+func summaryLocation(loc *api.Location) *SummaryLocation {
+	if loc == nil {
+		return nil
+	}
+	return &SummaryLocation{loc.File, loc.Line, loc.Column, loc.Length, loc.LineText, loc.Suggestion}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+
+	"github.com/dlw93/cvbuild/bundler"
+)
+
+func TestWriteSummary(t *testing.T) {
+	l := &Logger{w: &strings.Builder{}, level: LogLevelSilent, start: time.Now()}
+	l.Warnings([]api.Message{{Text: "unused import", PluginName: "import-meta-url"}})
+	l.Error(&bundler.DependencyError{File: "index.html", Tag: "img", Attr: "src", Value: "a.png", Err: errors.New("not found")})
+
+	records := []bundler.BuildRecord{
+		{
+			Input:    "/src/main.js",
+			Duration: 1500 * time.Microsecond,
+			Result: api.BuildResult{
+				OutputFiles: []api.OutputFile{{Path: "/project/dist/main.js", Contents: []byte("main")}},
+				Warnings: []api.Message{{
+					Text:     "unused import",
+					Location: &api.Location{File: "src/main.js", Line: 1, Column: 7, Length: 3, LineText: "import a from 'a';"},
+				}},
+			},
+		},
+		{Input: "a.png", Result: api.BuildResult{Errors: []api.Message{{Text: "not found"}}}},
+	}
+
+	var b strings.Builder
+	if err := l.writeSummary(&b, "index.html", records); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal([]byte(b.String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	delete(got, "durationMs")
+
+	want := map[string]any{
+		"input": "index.html",
+		"builds": []any{
+			map[string]any{
+				"input":      "/src/main.js",
+				"outputs":    []any{map[string]any{"path": "/project/dist/main.js", "bytes": 4.0}},
+				"durationMs": 1.5,
+				"warnings": []any{map[string]any{
+					"text": "unused import",
+					"location": map[string]any{
+						"file": "src/main.js", "line": 1.0, "column": 7.0, "length": 3.0, "lineText": "import a from 'a';",
+					},
+				}},
+				"errors": []any{},
+			},
+			map[string]any{
+				"input":      "a.png",
+				"outputs":    []any{},
+				"durationMs": 0.0,
+				"warnings":   []any{},
+				"errors":     []any{map[string]any{"text": "not found"}},
+			},
+		},
+		"warnings": []any{map[string]any{"text": "unused import", "plugin": "import-meta-url"}},
+		"errors": []any{map[string]any{
+			"text":  "not found",
+			"notes": []any{map[string]any{"text": `The dependency was referenced by <img src="a.png"> in index.html`}},
+		}},
+		"successful": false,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}